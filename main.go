@@ -27,18 +27,23 @@ func main() {
 	configPtr := flag.String("config", "", "Path to config file")
 	flag.Parse()
 	streamdeckd.SetConfigPath(*configPtr)
+	streamdeckd.LoadState()
 
 	go listenForExitSignals()
 
 	go streamdeckd.InitDBUS()
 	go handleScreensaver()
 
-	go streamdeckd.UpdateApplication()
 	go streamdeckd.EnableVirtualKeyboard()
 
 	examples.RegisterBaseModules()
+	streamdeckd.RegisterBuiltinApplicationProviders()
 
 	streamdeckd.LoadConfig()
+	streamdeckd.StartRules()
+
+	// started after the config is loaded, so providers from modules are registered
+	go streamdeckd.UpdateApplication()
 
 	attemptConnection()
 }
@@ -91,6 +96,7 @@ func shutdown() {
 	log.Println("Cleaning up")
 	isRunning = false
 	streamdeckd.UnmountHandlers()
+	streamdeckd.KillCommands()
 	for s := range streamdeckd.Devs {
 		streamdeckd.Devs[s].Close()
 	}
@@ -0,0 +1,121 @@
+package streamdeckd
+
+// ExtendedActionsV1 are the actions streamdeckd runs that api.InputActions doesn't cover, they
+// can be set on keys, knob actions and macro steps
+type ExtendedActionsV1 struct {
+	TypeText string `json:"type_text,omitempty"`
+	// MouseClick is left, right or middle
+	MouseClick  string         `json:"mouse_click,omitempty"`
+	MouseMove   *MouseMoveV1   `json:"mouse_move,omitempty"`
+	MouseScroll *MouseScrollV1 `json:"mouse_scroll,omitempty"`
+	DBusCall    *DBusCallV1    `json:"dbus_call,omitempty"`
+	Http        *HTTPRequestV1 `json:"http,omitempty"`
+	MqttPublish *MqttPublishV1 `json:"mqtt_publish,omitempty"`
+	// LockInput locks the deck's input until it is unlocked, see InputLockV1
+	LockInput bool `json:"lock_input,omitempty"`
+}
+
+// ActionV1 is a single step of an `actions` list. Each step should set one of the standard
+// or extended actions, or Delay, steps run in order, one after the other
+type ActionV1 struct {
+	Command          string            `json:"command,omitempty"`
+	Keybind          string            `json:"keybind,omitempty"`
+	SwitchPage       int               `json:"switch_page,omitempty"`
+	Brightness       int               `json:"brightness,omitempty"`
+	Url              string            `json:"url,omitempty"`
+	ObsCommand       string            `json:"obs_command,omitempty"`
+	ObsCommandParams map[string]string `json:"obs_command_params,omitempty"`
+	// CommandOptions changes how Command is run
+	CommandOptions *CommandOptionsV1 `json:"command_options,omitempty"`
+	ExtendedActionsV1
+	// Wait holds the macro until Command exits
+	Wait bool `json:"wait,omitempty"`
+	// Delay pauses the macro, in ms
+	Delay int `json:"delay,omitempty"`
+}
+
+func (a *ActionV1) GetSwitchPage() int {
+	return a.SwitchPage
+}
+
+func (a *ActionV1) GetKeyBind() string {
+	return a.Keybind
+}
+
+func (a *ActionV1) GetCommand() string {
+	return a.Command
+}
+
+func (a *ActionV1) GetBrightness() int {
+	return a.Brightness
+}
+
+func (a *ActionV1) GetUrl() string {
+	return a.Url
+}
+
+func (a *ActionV1) GetObsCommand() string {
+	return a.ObsCommand
+}
+
+func (a *ActionV1) GetObsCommandParams() map[string]string {
+	return a.ObsCommandParams
+}
+
+// RunAction runs a single action on its own, rather than as a step of a macro
+func (im *InputManager) RunAction(action *ActionV1) {
+	im.handleStandardActions(action, action.CommandOptions, -1)
+	go im.handleExtendedActions(&action.ExtendedActionsV1, -1)
+}
+
+// RunMacro runs actions as a macro, for macros that don't belong to a key or knob
+func (im *InputManager) RunMacro(id string, actions []ActionV1) {
+	im.macros.Run(im, id, actions, false)
+}
+
+// handleExtendedActions blocks until every action has run, so macros keep their order.
+// keyIndex is the key to show replies on, or -1 for none
+func (im *InputManager) handleExtendedActions(ea *ExtendedActionsV1, keyIndex int) {
+	if ea == nil {
+		return
+	}
+	if ea.TypeText != "" {
+		err := TypeText(ea.TypeText)
+		if err != nil {
+			im.vdev.Logger().Println("[ERROR] Failed to type text:", err)
+		}
+	}
+	if ea.MouseMove != nil {
+		err := MouseMove(ea.MouseMove)
+		if err != nil {
+			im.vdev.Logger().Println("[ERROR] Failed to move mouse:", err)
+		}
+	}
+	if ea.MouseClick != "" {
+		err := MouseClick(ea.MouseClick)
+		if err != nil {
+			im.vdev.Logger().Println("[ERROR] Failed to click mouse:", err)
+		}
+	}
+	if ea.MouseScroll != nil {
+		err := MouseScroll(ea.MouseScroll)
+		if err != nil {
+			im.vdev.Logger().Println("[ERROR] Failed to scroll mouse:", err)
+		}
+	}
+	if ea.DBusCall != nil {
+		im.callDBus(ea.DBusCall, keyIndex)
+	}
+	if ea.Http != nil {
+		im.sendHTTPRequest(ea.Http, keyIndex)
+	}
+	if ea.MqttPublish != nil {
+		err := mqttConnection.Publish(ea.MqttPublish)
+		if err != nil {
+			im.vdev.Logger().Println("[ERROR] Failed to publish to", ea.MqttPublish.Topic, ":", err)
+		}
+	}
+	if ea.LockInput {
+		im.SetInputLocked(true)
+	}
+}
@@ -1,33 +1,68 @@
 package streamdeckd
 
-import "log"
+import (
+	"log"
+	"sync"
+)
+
+// ApplicationContext describes the focused window, not every detection method can fill in
+// every field, Class is the only one that is always set
+type ApplicationContext struct {
+	Class     string `json:"class"`
+	Title     string `json:"title,omitempty"`
+	Pid       int    `json:"pid,omitempty"`
+	Exe       string `json:"exe,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+}
 
 type IApplicationManager interface {
 	SetApplication(application string)
-	AttachListener(listener func(application string))
+	SetContext(context ApplicationContext)
+	AttachListener(listener func(context ApplicationContext))
 	GetApplication() string
+	GetContext() ApplicationContext
 }
 
 type ApplicationManager struct {
-	listeners         []func(application string)
-	activeApplication string
+	mu            sync.Mutex
+	listeners     []func(context ApplicationContext)
+	activeContext ApplicationContext
 }
 
 func (am *ApplicationManager) SetApplication(application string) {
+	am.SetContext(ApplicationContext{Class: application})
+}
+
+func (am *ApplicationManager) SetContext(context ApplicationContext) {
+	am.mu.Lock()
+	if am.activeContext == context {
+		am.mu.Unlock()
+		return
+	}
+	if am.activeContext.Class != context.Class {
+		log.Println("Application updated to: " + context.Class)
+	}
+	am.activeContext = context
+	listeners := am.listeners
+	am.mu.Unlock()
 
-	if am.activeApplication != application {
-		log.Println("Application updated to: " + application)
-		am.activeApplication = application
-		for _, listener := range am.listeners {
-			go listener(application)
-		}
+	for _, listener := range listeners {
+		go listener(context)
 	}
 }
 
-func (am *ApplicationManager) AttachListener(listener func(application string)) {
+func (am *ApplicationManager) AttachListener(listener func(context ApplicationContext)) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
 	am.listeners = append(am.listeners, listener)
 }
 
 func (am *ApplicationManager) GetApplication() string {
-	return am.activeApplication
+	return am.GetContext().Class
+}
+
+func (am *ApplicationManager) GetContext() ApplicationContext {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return am.activeContext
 }
@@ -0,0 +1,143 @@
+package streamdeckd
+
+import (
+	"cmp"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// applicationFields are the fields an application entry can match on, in order of precedence.
+// An entry is either a plain class name, or "<field>:<glob>" or "<field>~<regex>", e.g.
+// "title:*Slack*" or "exe~^/usr/lib/firefox/"
+var applicationFields = []string{"title", "exe", "pid", "workspace", "class"}
+
+type applicationPattern struct {
+	entry string
+	field string
+	// rank is the position of the field in applicationFields, exact class names rank just
+	// above class patterns
+	rank  int
+	regex *regexp.Regexp
+}
+
+var applicationPatterns sync.Map
+
+// parseApplicationPattern returns nil for entries that are a plain class name
+func parseApplicationPattern(entry string) *applicationPattern {
+	if cached, ok := applicationPatterns.Load(entry); ok {
+		return cached.(*applicationPattern)
+	}
+	var pattern *applicationPattern
+	for rank, field := range applicationFields {
+		var expr string
+		if glob, ok := strings.CutPrefix(entry, field+":"); ok {
+			expr = globToRegex(glob)
+		} else if regex, ok := strings.CutPrefix(entry, field+"~"); ok {
+			expr = regex
+		} else {
+			continue
+		}
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			log.Println("Invalid application pattern", entry, err)
+			break
+		}
+		if field == "class" {
+			rank += 1
+		}
+		pattern = &applicationPattern{entry: entry, field: field, rank: rank, regex: regex}
+		break
+	}
+	applicationPatterns.Store(entry, pattern)
+	return pattern
+}
+
+func globToRegex(glob string) string {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return expr.String()
+}
+
+func (p *applicationPattern) matches(context ApplicationContext) bool {
+	var value string
+	switch p.field {
+	case "title":
+		value = context.Title
+	case "exe":
+		value = context.Exe
+	case "pid":
+		if context.Pid == 0 {
+			return false
+		}
+		value = strconv.Itoa(context.Pid)
+	case "workspace":
+		value = context.Workspace
+	case "class":
+		value = context.Class
+	}
+	return p.regex.MatchString(value)
+}
+
+// matchApplication picks the entry of an application map to use for context. Patterns are
+// ranked by the field they match on, title first, then exe, pid, workspace, an exact class
+// name, and lastly class patterns. Longer patterns win between entries of the same rank.
+// The default entry "" is returned when nothing matches.
+func matchApplication[T any](entries map[string]T, context ApplicationContext) string {
+	var matches []*applicationPattern
+	for entry := range entries {
+		if entry == "" {
+			continue
+		}
+		pattern := parseApplicationPattern(entry)
+		if pattern == nil {
+			if entry == context.Class {
+				matches = append(matches, &applicationPattern{entry: entry, rank: len(applicationFields) - 1})
+			}
+			continue
+		}
+		if pattern.matches(context) {
+			matches = append(matches, pattern)
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+	best := slices.MinFunc(matches, func(a, b *applicationPattern) int {
+		return cmp.Or(
+			cmp.Compare(a.rank, b.rank),
+			cmp.Compare(len(b.entry), len(a.entry)),
+			cmp.Compare(a.entry, b.entry),
+		)
+	})
+	return best.entry
+}
+
+// matchesAnyApplication checks context against a list of entries, in the same form as the keys
+// of an application map
+func matchesAnyApplication(entries []string, context ApplicationContext) bool {
+	for _, entry := range entries {
+		pattern := parseApplicationPattern(entry)
+		if pattern == nil && entry == context.Class {
+			return true
+		}
+		if pattern != nil && pattern.matches(context) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package streamdeckd
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultApplicationProviderRetries = 10
+	applicationProviderMaxBackoff     = 30 * time.Second
+	// a provider that ran for this long before failing is treated as having worked, and its
+	// retries start again from zero
+	applicationProviderHealthyRun = time.Minute
+)
+
+// ApplicationProvider reports the focused window, for a desktop session or window manager
+type ApplicationProvider interface {
+	// Available reports if the provider can be used in the current session
+	Available() bool
+	// Start sends the focused window to contexts, and again every time it changes. It blocks
+	// until the provider fails, or Stop is called, in which case it returns nil
+	Start(contexts chan<- ApplicationContext) error
+	Stop()
+}
+
+type ApplicationDetectionV1 struct {
+	// Providers are tried in order, the next one is used when a provider is unavailable in
+	// the session, or keeps failing. Every registered provider is tried when empty
+	Providers []string `json:"providers,omitempty"`
+	// Retries is how many times in a row a provider may fail before falling back to the next
+	Retries int `json:"retries,omitempty"`
+	// External turns off every provider, the focused window is only set over D-Bus
+	External bool `json:"external,omitempty"`
+	// SettleDelay is how long a window needs to stay focused before layouts change, in ms
+	SettleDelay int `json:"settle_delay,omitempty"`
+	// Ignore lists windows that never change layouts, such as launchers and popups, in the
+	// same form as application entries
+	Ignore []string `json:"ignore,omitempty"`
+}
+
+type applicationProviderEntry struct {
+	name        string
+	newProvider func() ApplicationProvider
+}
+
+var applicationProviders []applicationProviderEntry
+
+func RegisterApplicationProvider(name string, newProvider func() ApplicationProvider) {
+	for _, entry := range applicationProviders {
+		if entry.name == name {
+			log.Println("Application provider already loaded: " + name)
+			return
+		}
+	}
+	log.Println("Loaded application provider " + name)
+	applicationProviders = append(applicationProviders, applicationProviderEntry{name: name, newProvider: newProvider})
+}
+
+func AvailableApplicationProviders() []string {
+	var names []string
+	for _, entry := range applicationProviders {
+		names = append(names, entry.name)
+	}
+	return names
+}
+
+func findApplicationProvider(name string) func() ApplicationProvider {
+	for _, entry := range applicationProviders {
+		if entry.name == name {
+			return entry.newProvider
+		}
+	}
+	return nil
+}
+
+func applicationDetectionSettings() ApplicationDetectionV1 {
+	settings := ApplicationDetectionV1{}
+	if configExt != nil && configExt.ApplicationDetection != nil {
+		settings = *configExt.ApplicationDetection
+	}
+	if len(settings.Providers) == 0 {
+		settings.Providers = AvailableApplicationProviders()
+	}
+	if settings.Retries == 0 {
+		settings.Retries = defaultApplicationProviderRetries
+	}
+	return settings
+}
+
+type ApplicationDetection struct {
+	mu       sync.Mutex
+	provider ApplicationProvider
+	settings *ApplicationDetectionV1
+	restart  chan struct{}
+}
+
+var applicationDetection = &ApplicationDetection{restart: make(chan struct{}, 1)}
+
+// UpdateApplication runs the configured application providers, falling back down the list as
+// they fail, and starting again from the top once every one of them has
+func UpdateApplication() {
+	contexts := make(chan ApplicationContext, 10)
+	go func() {
+		for context := range contexts {
+			setApplicationContext(context)
+		}
+	}()
+	for {
+		settings := applicationDetectionSettings()
+		applicationDetection.mu.Lock()
+		applicationDetection.settings = &settings
+		applicationDetection.mu.Unlock()
+
+		if settings.External {
+			log.Println("Application detection is external, waiting for SetActiveApplication over D-Bus")
+			<-applicationDetection.restart
+			continue
+		}
+
+		available, restarted := applicationDetection.run(settings, contexts)
+		if restarted {
+			continue
+		}
+		if !available {
+			log.Println("[WARN] No application provider is available in this session, application based configuration is unavailable")
+			<-applicationDetection.restart
+			continue
+		}
+		log.Println("[WARN] Every application provider has failed, application based configuration is unavailable for", applicationProviderMaxBackoff)
+		applicationDetection.wait(applicationProviderMaxBackoff)
+	}
+}
+
+// run tries each provider in turn, it reports if any provider was available, and if it
+// returned early because the settings changed
+func (ad *ApplicationDetection) run(settings ApplicationDetectionV1, contexts chan<- ApplicationContext) (bool, bool) {
+	available := false
+	for _, name := range settings.Providers {
+		newProvider := findApplicationProvider(name)
+		if newProvider == nil {
+			log.Println("Could not find application provider:", name)
+			continue
+		}
+		provider := newProvider()
+		if !provider.Available() {
+			continue
+		}
+		available = true
+
+		failures := 0
+		backoff := time.Second
+		for failures < settings.Retries {
+			select {
+			case <-ad.restart:
+				return available, true
+			default:
+			}
+			ad.mu.Lock()
+			ad.provider = provider
+			ad.mu.Unlock()
+
+			log.Println("Detecting applications with", name)
+			started := time.Now()
+			err := provider.Start(contexts)
+
+			ad.mu.Lock()
+			ad.provider = nil
+			ad.mu.Unlock()
+
+			if time.Since(started) > applicationProviderHealthyRun {
+				failures = 0
+				backoff = time.Second
+			}
+			failures += 1
+			log.Println("[WARN] Application provider", name, "stopped, retrying in", backoff, err)
+			if ad.wait(backoff) {
+				return available, true
+			}
+			backoff = min(backoff*2, applicationProviderMaxBackoff)
+		}
+		log.Println("[WARN] Application provider", name, "failed", failures, "times in a row, falling back to the next provider")
+	}
+	return available, false
+}
+
+// wait sleeps for duration, returning early with true if detection needs restarting
+func (ad *ApplicationDetection) wait(duration time.Duration) bool {
+	select {
+	case <-ad.restart:
+		return true
+	case <-time.After(duration):
+		return false
+	}
+}
+
+// Reconfigure restarts detection if the application_detection settings that affect the
+// providers have changed
+func (ad *ApplicationDetection) Reconfigure() {
+	settings := applicationDetectionSettings()
+	settings.SettleDelay, settings.Ignore = 0, nil
+	ad.mu.Lock()
+	var previous ApplicationDetectionV1
+	if ad.settings != nil {
+		previous = *ad.settings
+		previous.SettleDelay, previous.Ignore = 0, nil
+	}
+	if ad.settings == nil || reflect.DeepEqual(previous, settings) {
+		ad.mu.Unlock()
+		return
+	}
+	provider := ad.provider
+	ad.mu.Unlock()
+
+	select {
+	case ad.restart <- struct{}{}:
+	default:
+	}
+	if provider != nil {
+		provider.Stop()
+	}
+}
+
+var settleSem sync.Mutex
+var settleTimer *time.Timer
+
+// setApplicationContext fills in the executable path from the PID, if the provider reported one,
+// then passes the context on once it has settled, unless it is ignored
+func setApplicationContext(context ApplicationContext) {
+	context.Class = strings.Trim(context.Class, "\n")
+	if context.Pid != 0 && context.Exe == "" {
+		context.Exe = processExe(context.Pid)
+	}
+
+	settings := applicationDetectionSettings()
+	if matchesAnyApplication(settings.Ignore, context) {
+		return
+	}
+
+	settleSem.Lock()
+	defer settleSem.Unlock()
+	if settleTimer != nil {
+		settleTimer.Stop()
+		settleTimer = nil
+	}
+	if settings.SettleDelay <= 0 {
+		applicationManager.SetContext(context)
+		return
+	}
+	settleTimer = time.AfterFunc(time.Duration(settings.SettleDelay)*time.Millisecond, func() {
+		applicationManager.SetContext(context)
+	})
+}
+
+// desktopIs checks XDG_CURRENT_DESKTOP, which can hold several colon separated names
+func desktopIs(name string) bool {
+	for _, desktop := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		if desktop == name {
+			return true
+		}
+	}
+	return false
+}
@@ -50,6 +50,9 @@ func (bg *Backgrounder) SetLcdBackground(backgrounder api.LcdBackgrounder) {
 	}
 
 	if backgrounder.GetTouchPanelBackgroundBuff() != nil {
+		for index := range backgrounder.GetTouchPanelBackgroundBuff() {
+			bg.vdev.SetPanelBackground(index, bg.vdev.PageManager().GetPage())
+		}
 		return
 	}
 
@@ -101,6 +104,9 @@ func (bg *Backgrounder) SetKeyBackground(backgrounder api.KeyGridBackgrounder) {
 	}
 
 	if backgrounder.GetKeyGridBackgroundBuff() != nil {
+		for index := range backgrounder.GetKeyGridBackgroundBuff() {
+			bg.vdev.SetKeyBackground(index, bg.vdev.PageManager().GetPage())
+		}
 		return
 	}
 
@@ -148,6 +154,7 @@ func (bg *Backgrounder) SetIndividualLcdBackground(backgrounder api.LcdSegmentBa
 	}
 
 	if backgrounder.GetTouchPanelBackgroundBuff() != nil {
+		bg.vdev.SetPanelBackground(index, bg.vdev.PageManager().GetPage())
 		return
 	}
 
@@ -191,6 +198,7 @@ func (bg *Backgrounder) SetIndividualKeyBackground(backgrounder api.KeyBackgroun
 	}
 
 	if backgrounder.GetKeyBackgroundBuff() != nil {
+		bg.vdev.SetKeyBackground(index, bg.vdev.PageManager().GetPage())
 		return
 	}
 
@@ -202,6 +210,8 @@ func (bg *Backgrounder) SetIndividualKeyBackground(backgrounder api.KeyBackgroun
 
 	img = api.ResizeImage(img, bg.vdev.SdInfo().IconSize)
 
+	backgrounder.SetKeyBackgroundBuff(img)
+
 	bg.vdev.SetKeyBackground(index, bg.vdev.PageManager().GetPage())
 }
 
@@ -209,11 +219,11 @@ func (bg *Backgrounder) AttachPageChangeListener() {
 
 	bg.vdev.PageManager().AttachListener(func(newPage, _ int) {
 
-		currentPage := bg.vdev.Config().Pages[newPage]
+		currentPage := &bg.vdev.Config().Pages[newPage]
 
-		go bg.SetKeyBackground(&currentPage)
+		go bg.SetKeyBackground(currentPage)
 
-		go bg.SetLcdBackground(&currentPage)
+		go bg.SetLcdBackground(currentPage)
 
 		for i := range currentPage.Keys {
 			key := &currentPage.Keys[i]
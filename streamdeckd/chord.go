@@ -0,0 +1,212 @@
+package streamdeckd
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultChordWindow = 100 * time.Millisecond
+
+// ChordV1 runs its actions when its keys are pressed together, in place of the keys' own
+// actions
+type ChordV1 struct {
+	Keys []int `json:"keys"`
+	ActionV1
+	Actions         []ActionV1 `json:"actions,omitempty"`
+	CancelOnRepress bool       `json:"cancel_on_repress,omitempty"`
+}
+
+type heldPress struct {
+	keyIndex int
+	press    func()
+}
+
+// chordGroup holds back the presses of keys that are part of a chord, until it can tell if
+// they are being pressed together
+type chordGroup struct {
+	mu         sync.Mutex
+	held       []heldPress
+	generation int
+	// consumed are the keys pressed as part of a chord, their releases are ignored
+	consumed map[int]bool
+}
+
+func (c *ChordV1) matches(keys []int) bool {
+	return len(c.Keys) == len(keys) && c.contains(keys)
+}
+
+func (c *ChordV1) contains(keys []int) bool {
+	for _, key := range keys {
+		if !slices.Contains(c.Keys, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// pageChords returns the chords of the current page, and how long to wait for the rest of a
+// chord's keys
+func (im *InputManager) pageChords() ([]ChordV1, time.Duration) {
+	deckExt := findDeckExt(im.vdev.Serial())
+	pageExt := deckExt.Page(im.vdev.PageManager().GetPage())
+	if pageExt == nil || len(pageExt.Chords) == 0 {
+		return nil, 0
+	}
+	window := defaultChordWindow
+	if deckExt.ChordWindow > 0 {
+		window = time.Duration(deckExt.ChordWindow) * time.Millisecond
+	}
+	return pageExt.Chords, window
+}
+
+// holdForChord returns whether a key press is held back, as it may be the start of a chord.
+// press runs the key's own actions, if it turns out not to be
+func (im *InputManager) holdForChord(keyIndex int, press func()) bool {
+	chords, window := im.pageChords()
+	if len(chords) == 0 {
+		return false
+	}
+	g := &im.chords
+	g.mu.Lock()
+	keys := append(g.keys(), keyIndex)
+	if !anyChordContains(chords, keys) {
+		flushed := g.take()
+		keys = []int{keyIndex}
+		if !anyChordContains(chords, keys) {
+			g.mu.Unlock()
+			runPresses(flushed)
+			return false
+		}
+		defer runPresses(flushed)
+	}
+	g.held = append(g.held, heldPress{keyIndex: keyIndex, press: press})
+	if chord := matchingChord(chords, keys); chord != nil && !anyChordContains(chords, keys, chord) {
+		g.take()
+		g.consume(keys)
+		g.mu.Unlock()
+		im.runChord(chord)
+		return true
+	}
+	if len(g.held) == 1 {
+		generation := g.generation
+		time.AfterFunc(window, func() {
+			im.endChordWindow(chords, generation)
+		})
+	}
+	g.mu.Unlock()
+	return true
+}
+
+// endChordWindow runs the chord the held keys make, or the keys' own actions if they don't
+// make one
+func (im *InputManager) endChordWindow(chords []ChordV1, generation int) {
+	g := &im.chords
+	g.mu.Lock()
+	if g.generation != generation || len(g.held) == 0 {
+		g.mu.Unlock()
+		return
+	}
+	keys := g.keys()
+	held := g.take()
+	chord := matchingChord(chords, keys)
+	if chord != nil {
+		g.consume(keys)
+	}
+	g.mu.Unlock()
+	if chord != nil {
+		im.runChord(chord)
+		return
+	}
+	runPresses(held)
+}
+
+// releaseFromChord returns whether a key's release should be ignored, as it was pressed as
+// part of a chord. Releasing a held key runs the held presses, as it can't be part of a chord
+// with keys pressed after it
+func (im *InputManager) releaseFromChord(keyIndex int) bool {
+	g := &im.chords
+	g.mu.Lock()
+	if g.consumed[keyIndex] {
+		delete(g.consumed, keyIndex)
+		g.mu.Unlock()
+		return true
+	}
+	var held []heldPress
+	if slices.Contains(g.keys(), keyIndex) {
+		held = g.take()
+	}
+	g.mu.Unlock()
+	runPresses(held)
+	return false
+}
+
+func (im *InputManager) runChord(chord *ChordV1) {
+	im.vdev.Logger().Println("Chord", chord.Keys, "pressed")
+	im.RunAction(&chord.ActionV1)
+	if len(chord.Actions) > 0 {
+		im.macros.Run(im, chordMacroId(chord.Keys), chord.Actions, chord.CancelOnRepress)
+	}
+}
+
+// cancel drops any held presses without running them
+func (g *chordGroup) cancel() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.take()
+}
+
+func (g *chordGroup) keys() []int {
+	keys := make([]int, len(g.held))
+	for i, press := range g.held {
+		keys[i] = press.keyIndex
+	}
+	return keys
+}
+
+// take empties the group, returning the presses it held
+func (g *chordGroup) take() []heldPress {
+	held := g.held
+	g.held = nil
+	g.generation++
+	return held
+}
+
+func (g *chordGroup) consume(keys []int) {
+	if g.consumed == nil {
+		g.consumed = make(map[int]bool)
+	}
+	for _, key := range keys {
+		g.consumed[key] = true
+	}
+}
+
+func runPresses(held []heldPress) {
+	for _, press := range held {
+		press.press()
+	}
+}
+
+func matchingChord(chords []ChordV1, keys []int) *ChordV1 {
+	for i := range chords {
+		if chords[i].matches(keys) {
+			return &chords[i]
+		}
+	}
+	return nil
+}
+
+// anyChordContains returns whether keys are all part of one of chords, other than except
+func anyChordContains(chords []ChordV1, keys []int, except ...*ChordV1) bool {
+	for i := range chords {
+		if !slices.Contains(except, &chords[i]) && chords[i].contains(keys) {
+			return true
+		}
+	}
+	return false
+}
+
+func chordMacroId(keys []int) string {
+	return fmt.Sprintf("chord %v", keys)
+}
@@ -0,0 +1,170 @@
+package streamdeckd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CommandOptionsV1 changes how a key or knob's command is run, commands with options are
+// waited on, instead of being started and forgotten about
+type CommandOptionsV1 struct {
+	Cwd string            `json:"cwd,omitempty"`
+	Env map[string]string `json:"env,omitempty"`
+	// Timeout kills the command after this long, in ms
+	Timeout int `json:"timeout,omitempty"`
+	// KillOnShutdown kills the command, and anything it started, when streamdeckd exits
+	KillOnShutdown bool `json:"kill_on_shutdown,omitempty"`
+	// LogOutput copies the command's stdout and stderr into streamdeckd's log
+	LogOutput bool `json:"log_output,omitempty"`
+	// Feedback shows the command's progress on the key, any of spinner, flash and output
+	Feedback []string `json:"feedback,omitempty"`
+}
+
+func (o *CommandOptionsV1) hasFeedback(feedback string) bool {
+	if o == nil {
+		return false
+	}
+	for _, f := range o.Feedback {
+		if f == feedback {
+			return true
+		}
+	}
+	return false
+}
+
+// maxCommandOutput is the most of a command's stdout that's kept, more than would fit on a key
+const maxCommandOutput = 1024
+
+// maxLogLine is the longest line of a command's output that's copied into the log
+const maxLogLine = 1024 * 1024
+
+var commandGroupsSem sync.Mutex
+var commandGroups = map[*exec.Cmd]struct{}{}
+
+// RunCommandWithOptions runs command and waits for it to exit, returning its stdout and exit code.
+// Cancelling ctx kills the command's process group
+func RunCommandWithOptions(ctx context.Context, command string, options *CommandOptionsV1, logger *log.Logger) (string, int, error) {
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(options.Timeout)*time.Millisecond)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Dir = expandHome(options.Cwd)
+	if len(options.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range options.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+	killGroupOnCancel(cmd)
+
+	stdout := &limitedBuffer{limit: maxCommandOutput}
+	cmd.Stdout = stdout
+	if options.LogOutput {
+		stdoutLog := logLines(logger, command+" stdout:")
+		stderrLog := logLines(logger, command+" stderr:")
+		defer stdoutLog.Close()
+		defer stderrLog.Close()
+		cmd.Stdout = io.MultiWriter(stdout, stdoutLog)
+		cmd.Stderr = stderrLog
+	}
+
+	err := cmd.Start()
+	if err != nil {
+		return "", -1, err
+	}
+	logger.Println(command, "has been started with pid", cmd.Process.Pid)
+	if options.KillOnShutdown {
+		commandGroupsSem.Lock()
+		commandGroups[cmd] = struct{}{}
+		commandGroupsSem.Unlock()
+		defer func() {
+			commandGroupsSem.Lock()
+			delete(commandGroups, cmd)
+			commandGroupsSem.Unlock()
+		}()
+	}
+
+	err = cmd.Wait()
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Println(command, "timed out after", time.Duration(options.Timeout)*time.Millisecond)
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return stdout.String(), -1, err
+	}
+	return stdout.String(), cmd.ProcessState.ExitCode(), nil
+}
+
+// killGroupOnCancel puts cmd in its own process group, so cancelling its context takes
+// anything it started down with it
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// background processes the command leaves holding its output open don't hold up Wait
+	cmd.WaitDelay = time.Second
+}
+
+// KillCommands kills the commands started with kill_on_shutdown, and their process groups
+func KillCommands() {
+	commandGroupsSem.Lock()
+	defer commandGroupsSem.Unlock()
+	for cmd := range commandGroups {
+		err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func expandHome(path string) string {
+	if len(path) > 0 && path[0] == '~' {
+		return os.Getenv("HOME") + path[1:]
+	}
+	return path
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the rest, without failing
+// the writes, so a command with a lot of output isn't cut off
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// logLines sends each line written to the returned writer to logger, until it is closed. A line
+// longer than maxLogLine stops the logging, but the rest is still read so the writer never blocks
+func logLines(logger *log.Logger, prefix string) *io.PipeWriter {
+	reader, writer := io.Pipe()
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(nil, maxLogLine)
+		for scanner.Scan() {
+			logger.Println(prefix, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			logger.Println(prefix, err)
+			io.Copy(io.Discard, reader)
+		}
+	}()
+	return writer
+}
@@ -0,0 +1,164 @@
+package streamdeckd
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/unix-streamdeck/api/v2"
+)
+
+const (
+	spinnerFrameDuration = 100 * time.Millisecond
+	spinnerDots          = 8
+	flashDuration        = 500 * time.Millisecond
+)
+
+var (
+	flashSuccess = color.RGBA{R: 0x00, G: 0xA0, B: 0x00, A: 0x90}
+	flashFailure = color.RGBA{R: 0xC0, G: 0x00, B: 0x00, A: 0x90}
+)
+
+// runCommandWithOptions runs a command that has command_options, keyIndex is the key to show
+// feedback on, or -1 for none
+func (im *InputManager) runCommandWithOptions(ctx context.Context, command string, options *CommandOptionsV1, keyIndex int) {
+	page := im.vdev.PageManager().GetPage()
+	if keyIndex < 0 {
+		options = &CommandOptionsV1{Cwd: options.Cwd, Env: options.Env, Timeout: options.Timeout, KillOnShutdown: options.KillOnShutdown, LogOutput: options.LogOutput}
+	}
+
+	var stopSpinner, spinnerStopped chan struct{}
+	if options.hasFeedback("spinner") {
+		stopSpinner = make(chan struct{})
+		spinnerStopped = make(chan struct{})
+		go im.spin(keyIndex, page, stopSpinner, spinnerStopped)
+	}
+
+	stdout, exitCode, err := RunCommandWithOptions(ctx, command, options, im.vdev.Logger())
+	if err != nil {
+		im.vdev.Logger().Println("There was a problem running", command, ":", err)
+	} else if exitCode != 0 {
+		im.vdev.Logger().Println(command, "exited with code", exitCode)
+	}
+
+	if stopSpinner != nil {
+		close(stopSpinner)
+		<-spinnerStopped
+	}
+	if keyIndex < 0 || im.vdev.PageManager().GetPage() != page {
+		return
+	}
+
+	var result image.Image
+	if options.hasFeedback("output") {
+		result = im.drawOutput(strings.TrimSpace(stdout))
+	}
+	im.showResult(keyIndex, page, options.hasFeedback("flash"), err == nil && exitCode == 0, result)
+}
+
+// showResult flashes keyIndex green or red for success, if flash is set, then leaves result over
+// the key, unless the page has changed from page
+func (im *InputManager) showResult(keyIndex int, page int, flash bool, success bool, result image.Image) {
+	if flash {
+		colour := flashSuccess
+		if !success {
+			colour = flashFailure
+		}
+		im.setFeedbackOverlay(im.filledOverlay(colour), keyIndex)
+		time.Sleep(flashDuration)
+		if im.vdev.PageManager().GetPage() != page {
+			return
+		}
+	}
+	im.setFeedbackOverlay(result, keyIndex)
+}
+
+func (im *InputManager) spin(keyIndex int, page int, stop chan struct{}, stopped chan struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(spinnerFrameDuration)
+	defer ticker.Stop()
+	for frame := 0; ; frame++ {
+		if im.vdev.PageManager().GetPage() != page {
+			return
+		}
+		im.setFeedbackOverlay(im.drawSpinner(frame), keyIndex)
+		select {
+		case <-stop:
+			im.setFeedbackOverlay(nil, keyIndex)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setFeedbackOverlay keeps track of the keys showing feedback, so it can be cleared when the
+// page changes
+func (im *InputManager) setFeedbackOverlay(img image.Image, keyIndex int) {
+	im.feedbackSem.Lock()
+	if im.feedbackKeys == nil {
+		im.feedbackKeys = make(map[int]bool)
+	}
+	if img == nil {
+		delete(im.feedbackKeys, keyIndex)
+	} else {
+		im.feedbackKeys[keyIndex] = true
+	}
+	im.feedbackSem.Unlock()
+	im.vdev.SetKeyOverlay(img, keyIndex, FeedbackLayer)
+}
+
+func (im *InputManager) clearFeedback() {
+	im.feedbackSem.Lock()
+	keys := im.feedbackKeys
+	im.feedbackKeys = nil
+	im.feedbackSem.Unlock()
+	for keyIndex := range keys {
+		im.vdev.SetKeyOverlay(nil, keyIndex, FeedbackLayer)
+	}
+}
+
+func (im *InputManager) drawSpinner(frame int) image.Image {
+	size := im.vdev.SdInfo().IconSize
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{A: 0x80}}, image.Point{}, draw.Src)
+	radius := float64(size) / 4
+	dotRadius := max(size/24, 2)
+	for i := 0; i < spinnerDots; i++ {
+		angle := 2 * math.Pi * float64(i) / spinnerDots
+		x := size/2 + int(radius*math.Sin(angle))
+		y := size/2 - int(radius*math.Cos(angle))
+		// the dot at frame is brightest, trailing off behind it
+		age := (frame - i + spinnerDots) % spinnerDots
+		alpha := uint8(0xFF - age*(0xFF/spinnerDots))
+		drawDot(img, x, y, dotRadius, color.RGBA{R: alpha, G: alpha, B: alpha, A: alpha})
+	}
+	return img
+}
+
+func (im *InputManager) filledOverlay(c color.RGBA) image.Image {
+	size := im.vdev.SdInfo().IconSize
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: c}, image.Point{}, draw.Src)
+	return img
+}
+
+func (im *InputManager) drawOutput(text string) image.Image {
+	if text == "" {
+		return nil
+	}
+	size := im.vdev.SdInfo().IconSize
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{A: 0xC0}}, image.Point{}, draw.Src)
+	withText, err := api.DrawText(img, text, api.DrawTextOptions{
+		VerticalAlignment: api.Center,
+	})
+	if err != nil {
+		im.vdev.Logger().Println(err)
+		return nil
+	}
+	return withText
+}
@@ -0,0 +1,33 @@
+package streamdeckd
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCommandLongOutput(t *testing.T) {
+	// a single line longer than the log's line limit, followed by more output
+	command := "head -c 2000000 /dev/zero | tr '\\0' x; echo; echo done"
+	done := make(chan struct{})
+	var stdout string
+	var err error
+	go func() {
+		defer close(done)
+		stdout, _, err = RunCommandWithOptions(context.Background(), command, &CommandOptionsV1{LogOutput: true}, log.New(io.Discard, "", 0))
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("command with a long line of output never finished")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stdout) != maxCommandOutput || strings.Trim(stdout, "x") != "" {
+		t.Errorf("kept %d bytes of output, want the first %d", len(stdout), maxCommandOutput)
+	}
+}
@@ -12,6 +12,10 @@ import (
 )
 
 var configPath string
+
+// configPathGiven is set when the config file was given on the command line, which is used
+// instead of the profile saved from the last run
+var configPathGiven bool
 var basicConfig = api.ConfigV3{
 	Modules: []string{},
 	Decks: []api.DeckV3{
@@ -37,6 +41,7 @@ func LoadConfig() {
 			log.Println(err)
 		}
 		config = &basicConfig
+		configExt = &ConfigExtV3{}
 		err = SaveConfig()
 		if err != nil {
 			log.Println(err)
@@ -48,6 +53,7 @@ func LoadConfig() {
 		}
 	}
 	tryConnectObs()
+	mqttConnection.Reconfigure()
 }
 
 func readConfig() (*api.ConfigV3, error) {
@@ -61,50 +67,83 @@ func readConfig() (*api.ConfigV3, error) {
 		log.Fatalln("Could not parse config, shutting down", err)
 		return &api.ConfigV3{}, err
 	}
+	// extended settings that don't parse are as fatal as the rest of the config, running on
+	// without them would silently drop every one of them
+	configExt, err = parseConfigExt(data)
+	if err != nil {
+		log.Fatalln("Could not parse config, shutting down", err)
+		return &api.ConfigV3{}, err
+	}
 	return &config, nil
 }
 
 func SetConfig(configString string) error {
 	configSem.Lock()
 	defer configSem.Unlock()
-	UnmountHandlers()
-	var err error
-	config = nil
-	err = json.Unmarshal([]byte(configString), &config)
+	var newConfig *api.ConfigV3
+	err := json.Unmarshal([]byte(configString), &newConfig)
 	if err != nil {
 		return err
 	}
-	for s := range Devs {
-		dev := Devs[s]
-		for i := range config.Decks {
-			if dev.Serial() == config.Decks[i].Serial {
-				dev.SetConfig(config.Decks[i])
-			}
-		}
+	// a config with bad extended settings, such as a rule's time, is refused as a whole
+	newConfigExt, err := parseConfigExt([]byte(configString))
+	if err != nil {
+		return err
 	}
+	UnmountHandlers()
+	config = newConfig
+	configExt = newConfigExt
+	applicationDetection.Reconfigure()
+	mqttConnection.Reconfigure()
+	applyDeckConfigs()
 	return nil
 }
 
 func ReloadConfig() error {
 	configSem.Lock()
 	defer configSem.Unlock()
+	return reloadConfig()
+}
+
+// reloadConfig must be called with configSem held
+func reloadConfig() error {
 	UnmountHandlers()
 	LoadConfig()
+	applicationDetection.Reconfigure()
+	applyDeckConfigs()
+	return nil
+}
+
+// applyDeckConfigs hands each connected deck its config. Decks the config doesn't have, such as
+// when switching to a profile made without them, get an empty page rather than keeping the
+// previous config's
+func applyDeckConfigs() {
 	for s := range Devs {
 		dev := Devs[s]
+		found := false
 		for i := range config.Decks {
 			if dev.Serial() == config.Decks[i].Serial {
 				dev.SetConfig(config.Decks[i])
+				found = true
 			}
 		}
+		if !found {
+			info := dev.SdInfo()
+			deck := api.DeckV3{Serial: dev.Serial(), Pages: []api.PageV3{makeEmptyPageConfig(info.Cols*info.Rows, info.KnobCols)}}
+			config.Decks = append(config.Decks, deck)
+			dev.SetConfig(deck)
+		}
 	}
-	return nil
 }
 
 func SaveConfig() error {
 	configSem.Lock()
 	defer configSem.Unlock()
-	return SaveFile(configPath, config)
+	merged, err := mergedConfig()
+	if err != nil {
+		return err
+	}
+	return SaveFile(configPath, merged)
 }
 
 func SaveFile(path string, value any) error {
@@ -132,6 +171,7 @@ func SaveFile(path string, value any) error {
 func SetConfigPath(path string) {
 	if path != "" {
 		configPath = path
+		configPathGiven = true
 	} else {
 		basePath := os.Getenv("HOME") + string(os.PathSeparator) + ".config"
 		if os.Getenv("XDG_CONFIG_HOME") != "" {
@@ -139,6 +179,7 @@ func SetConfigPath(path string) {
 		}
 		configPath = basePath + string(os.PathSeparator) + ".streamdeck-config.json"
 	}
+	defaultConfigPath = configPath
 }
 
 func findConfig(device *streamdeck.Device) api.DeckV3 {
@@ -153,23 +194,23 @@ func findConfig(device *streamdeck.Device) api.DeckV3 {
 
 func makeEmptyDeckConfig(device *streamdeck.Device) api.DeckV3 {
 	var pages []api.PageV3
-	pages = append(pages, makeEmptyPageConfig(device))
+	pages = append(pages, makeEmptyPageConfig(int(device.Rows)*int(device.Columns), int(device.Knobs)))
 	devConf := api.DeckV3{Serial: device.Serial, Pages: pages}
 	config.Decks = append(config.Decks, devConf)
 	_ = SaveConfig()
 	return devConf
 }
 
-func makeEmptyPageConfig(device *streamdeck.Device) api.PageV3 {
+func makeEmptyPageConfig(keys int, knobs int) api.PageV3 {
 	page := api.PageV3{}
-	for i := 0; i < int(device.Rows)*int(device.Columns); i++ {
+	for i := 0; i < keys; i++ {
 		applications := make(map[string]*api.KeyConfigV3)
 		applications[""] = &api.KeyConfigV3{}
 		page.Keys = append(page.Keys, api.KeyV3{
 			Application: applications,
 		})
 	}
-	for i := 0; i < int(device.Knobs); i++ {
+	for i := 0; i < knobs; i++ {
 		applications := make(map[string]*api.KnobConfigV3)
 		applications[""] = &api.KnobConfigV3{}
 		page.Knobs = append(page.Knobs, api.KnobV3{
@@ -0,0 +1,190 @@
+package streamdeckd
+
+import (
+	"encoding/json"
+)
+
+// ConfigExtV3 holds the options streamdeckd understands that are not part of api.ConfigV3.
+// It is read from the same config file as api.ConfigV3 and merged back into it when the
+// config is saved or sent over D-Bus, so the file stays a single document.
+type ConfigExtV3 struct {
+	ApplicationDetection *ApplicationDetectionV1 `json:"application_detection,omitempty"`
+	KeyboardLayout       string                  `json:"keyboard_layout,omitempty"`
+	MqttConnectionInfo   *MqttConnectionInfoV1   `json:"mqtt_connection_info,omitempty"`
+	Rules                []RuleV1                `json:"rules,omitempty"`
+	Decks                []DeckExtV3             `json:"decks,omitempty"`
+}
+
+type DeckExtV3 struct {
+	Serial                string           `json:"serial,omitempty"`
+	PageIndicator         *PageIndicatorV1 `json:"page_indicator,omitempty"`
+	ApplicationBrightness map[string]int   `json:"application_brightness,omitempty"`
+	PageCache             *PageCacheV1     `json:"page_cache,omitempty"`
+	Swipe                 *SwipeV1         `json:"swipe,omitempty"`
+	InputLock             *InputLockV1     `json:"input_lock,omitempty"`
+	// ChordWindow is how long, in ms, to wait for the rest of a chord's keys, defaults to 100
+	ChordWindow int         `json:"chord_window,omitempty"`
+	Pages       []PageExtV3 `json:"pages,omitempty"`
+}
+
+type PageExtV3 struct {
+	Name       string      `json:"name,omitempty"`
+	Brightness int         `json:"brightness,omitempty"`
+	Keys       []KeyExtV3  `json:"keys,omitempty"`
+	Knobs      []KnobExtV3 `json:"knobs,omitempty"`
+	Chords     []ChordV1   `json:"chords,omitempty"`
+}
+
+type KeyExtV3 struct {
+	Application map[string]*KeyConfigExtV3 `json:"application,omitempty"`
+}
+
+type KeyConfigExtV3 struct {
+	DynamicPage       string         `json:"dynamic_page,omitempty"`
+	DynamicPageFields map[string]any `json:"dynamic_page_fields,omitempty"`
+	Actions           []ActionV1     `json:"actions,omitempty"`
+	CancelOnRepress   bool           `json:"cancel_on_repress,omitempty"`
+	States            []KeyStateV1   `json:"states,omitempty"`
+	// StateCommand picks the state to show from its exit code, it runs whenever the page is shown
+	StateCommand string `json:"state_command,omitempty"`
+	// CommandOptions changes how the key's command is run
+	CommandOptions *CommandOptionsV1 `json:"command_options,omitempty"`
+	// MqttSubscribe changes how the key looks from the messages on a topic
+	MqttSubscribe *MqttSubscriptionV1 `json:"mqtt_subscribe,omitempty"`
+	// Confirm only runs the key's actions when it is pressed twice
+	Confirm *ConfirmV1 `json:"confirm,omitempty"`
+	ExtendedActionsV1
+}
+
+type KnobExtV3 struct {
+	Application map[string]*KnobConfigExtV3 `json:"application,omitempty"`
+}
+
+type KnobConfigExtV3 struct {
+	KnobPressAction    *KnobActionExtV3 `json:"knob_press_action,omitempty"`
+	KnobTurnUpAction   *KnobActionExtV3 `json:"knob_turn_up_action,omitempty"`
+	KnobTurnDownAction *KnobActionExtV3 `json:"knob_turn_down_action,omitempty"`
+	Scroll             *KnobScrollV1    `json:"scroll,omitempty"`
+	Turn               *KnobTurnV1      `json:"turn,omitempty"`
+	// PressedTurnUpAction and PressedTurnDownAction replace the turn actions while the knob is
+	// held down
+	PressedTurnUpAction   *ActionV1 `json:"pressed_turn_up_action,omitempty"`
+	PressedTurnDownAction *ActionV1 `json:"pressed_turn_down_action,omitempty"`
+	// ShortTapAction and LongTapAction run when the knob's segment of the touch screen is tapped
+	ShortTapAction *ActionV1 `json:"short_tap_action,omitempty"`
+	LongTapAction  *ActionV1 `json:"long_tap_action,omitempty"`
+	// Confirm only runs the knob's press and tap actions when they are done twice
+	Confirm *ConfirmV1 `json:"confirm,omitempty"`
+}
+
+type KnobActionExtV3 struct {
+	Actions         []ActionV1 `json:"actions,omitempty"`
+	CancelOnRepress bool       `json:"cancel_on_repress,omitempty"`
+	// CommandOptions changes how the action's command is run
+	CommandOptions *CommandOptionsV1 `json:"command_options,omitempty"`
+	ExtendedActionsV1
+}
+
+var configExt = &ConfigExtV3{}
+
+func parseConfigExt(data []byte) (*ConfigExtV3, error) {
+	var ext ConfigExtV3
+	err := json.Unmarshal(data, &ext)
+	if err != nil {
+		return &ConfigExtV3{}, err
+	}
+	return &ext, parseRules(ext.Rules)
+}
+
+func findDeckExt(serial string) *DeckExtV3 {
+	if configExt == nil {
+		return nil
+	}
+	for i := range configExt.Decks {
+		if configExt.Decks[i].Serial == serial {
+			return &configExt.Decks[i]
+		}
+	}
+	return nil
+}
+
+func (d *DeckExtV3) Page(page int) *PageExtV3 {
+	if d == nil || page < 0 || page >= len(d.Pages) {
+		return nil
+	}
+	return &d.Pages[page]
+}
+
+func (p *PageExtV3) Key(key int, application string) *KeyConfigExtV3 {
+	if p == nil || key < 0 || key >= len(p.Keys) {
+		return nil
+	}
+	return p.Keys[key].Application[application]
+}
+
+func (p *PageExtV3) Knob(knob int, application string) *KnobConfigExtV3 {
+	if p == nil || knob < 0 || knob >= len(p.Knobs) {
+		return nil
+	}
+	return p.Knobs[knob].Application[application]
+}
+
+// mergedConfig returns the running config with the daemon side options layered on top,
+// ready to be marshalled
+func mergedConfig() (any, error) {
+	var base any
+	baseString, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(baseString, &base)
+	if err != nil {
+		return nil, err
+	}
+	if configExt == nil {
+		return base, nil
+	}
+	var overlay any
+	overlayString, err := json.Marshal(configExt)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(overlayString, &overlay)
+	if err != nil {
+		return nil, err
+	}
+	return mergeJSON(base, overlay), nil
+}
+
+// mergeJSON layers overlay on top of base, objects are merged key by key and arrays
+// index by index, anything in overlay that has no counterpart in base is dropped for
+// arrays, so a page or deck removed from the base config does not come back
+func mergeJSON(base, overlay any) any {
+	switch o := overlay.(type) {
+	case map[string]any:
+		b, ok := base.(map[string]any)
+		if !ok {
+			return overlay
+		}
+		for k, v := range o {
+			if v == nil {
+				continue
+			}
+			b[k] = mergeJSON(b[k], v)
+		}
+		return b
+	case []any:
+		b, ok := base.([]any)
+		if !ok {
+			return overlay
+		}
+		for i := 0; i < len(b) && i < len(o); i++ {
+			b[i] = mergeJSON(b[i], o[i])
+		}
+		return b
+	case nil:
+		return base
+	default:
+		return overlay
+	}
+}
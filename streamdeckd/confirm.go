@@ -0,0 +1,146 @@
+package streamdeckd
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"time"
+
+	"github.com/unix-streamdeck/api/v2"
+	streamdeck "github.com/unix-streamdeck/driver"
+)
+
+const (
+	defaultConfirmTimeout = 3 * time.Second
+	defaultConfirmText    = "Press again"
+)
+
+var confirmColour = color.RGBA{R: 0x90, G: 0x00, B: 0x00, A: 0xD0}
+
+// ConfirmV1 guards a key, knob press or touch screen tap, the first press only asks for a
+// second, and the actions run if that comes within the timeout
+type ConfirmV1 struct {
+	// Timeout is in ms, defaults to 3 seconds
+	Timeout int `json:"timeout,omitempty"`
+	// Icon is shown while waiting for the second press, in place of Text
+	Icon string `json:"icon,omitempty"`
+	// Text is shown while waiting for the second press, defaults to "Press again"
+	Text string `json:"text,omitempty"`
+}
+
+func (c *ConfirmV1) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return defaultConfirmTimeout
+	}
+	return time.Duration(c.Timeout) * time.Millisecond
+}
+
+type pendingConfirm struct {
+	timer *time.Timer
+	clear func()
+}
+
+type confirmations struct {
+	mu      sync.Mutex
+	pending map[string]*pendingConfirm
+}
+
+// confirm returns true if id is waiting for its second press, otherwise it starts waiting,
+// calling clear when the wait is over
+func (c *confirmations) confirm(id string, timeout time.Duration, clear func()) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		c.pending = make(map[string]*pendingConfirm)
+	}
+	if pending, ok := c.pending[id]; ok {
+		pending.timer.Stop()
+		delete(c.pending, id)
+		go pending.clear()
+		return true
+	}
+	pending := &pendingConfirm{clear: clear}
+	pending.timer = time.AfterFunc(timeout, func() {
+		c.mu.Lock()
+		if c.pending[id] != pending {
+			c.mu.Unlock()
+			return
+		}
+		delete(c.pending, id)
+		c.mu.Unlock()
+		clear()
+	})
+	c.pending[id] = pending
+	return false
+}
+
+// cancelAll stops waiting for any second presses
+func (c *confirmations) cancelAll() {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+	for _, p := range pending {
+		p.timer.Stop()
+		p.clear()
+	}
+}
+
+// confirmKey returns whether a key press should run its actions, showing the confirm prompt
+// on the key if it is the first press
+func (im *InputManager) confirmKey(confirm *ConfirmV1, keyIndex int) bool {
+	id := fmt.Sprintf("key %d", keyIndex)
+	if im.confirms.confirm(id, confirm.timeout(), func() { im.vdev.SetKeyOverlay(nil, keyIndex, ConfirmLayer) }) {
+		return true
+	}
+	size := im.vdev.SdInfo().IconSize
+	im.vdev.SetKeyOverlay(im.drawConfirm(confirm, size, size), keyIndex, ConfirmLayer)
+	return false
+}
+
+// confirmKnob is confirmKey for knob presses and touch screen taps, showing the prompt on the
+// knob's segment of the touch screen
+func (im *InputManager) confirmKnob(confirm *ConfirmV1, knobIndex int, action string) bool {
+	id := fmt.Sprintf("knob %d %s", knobIndex, action)
+	if im.confirms.confirm(id, confirm.timeout(), func() { im.vdev.SetPanelOverlay(nil, knobIndex, ConfirmLayer) }) {
+		return true
+	}
+	info := im.vdev.SdInfo()
+	im.vdev.SetPanelOverlay(im.drawConfirm(confirm, info.LcdWidth, info.LcdHeight), knobIndex, ConfirmLayer)
+	return false
+}
+
+func knobConfirmAction(eventType streamdeck.InputEventType) string {
+	switch eventType {
+	case streamdeck.SCREEN_SHORT_TAP:
+		return "short tap"
+	case streamdeck.SCREEN_LONG_TAP:
+		return "long tap"
+	default:
+		return "press"
+	}
+}
+
+func (im *InputManager) drawConfirm(confirm *ConfirmV1, w, h int) image.Image {
+	if confirm.Icon != "" {
+		icon, err := LoadImage(confirm.Icon)
+		if err == nil {
+			return api.ResizeImageWH(icon, w, h)
+		}
+		im.vdev.Logger().Println(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: confirmColour}, image.Point{}, draw.Src)
+	text := confirm.Text
+	if text == "" {
+		text = defaultConfirmText
+	}
+	withText, err := api.DrawText(img, text, api.DrawTextOptions{VerticalAlignment: api.Center})
+	if err != nil {
+		im.vdev.Logger().Println(err)
+		return img
+	}
+	return withText
+}
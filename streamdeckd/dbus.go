@@ -8,6 +8,7 @@ import (
 	"image"
 	"image/png"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/godbus/dbus/v5"
@@ -27,7 +28,14 @@ type IStreamDeckDBus interface {
 	SetConfig(configString string) *dbus.Error
 	CommitConfig() *dbus.Error
 	GetModules() (string, *dbus.Error)
+	GetPageProviders() (string, *dbus.Error)
+	GetApplicationProviders() (string, *dbus.Error)
+	GetActiveApplication() (string, *dbus.Error)
+	SetActiveApplication(contextString string) *dbus.Error
 	PressButton(serial string, keyIndex int) *dbus.Error
+	SetKeyState(serial string, page int, keyIndex int, state int) *dbus.Error
+	SetInputLock(serial string, locked bool) *dbus.Error
+	FireEvent(name string) *dbus.Error
 	GetHandlerExample(serial string, keyString string) (string, *dbus.Error)
 	GetKnobHandlerExample(serial string, keyString string) (string, *dbus.Error)
 }
@@ -48,7 +56,11 @@ func (s StreamDeckDBus) GetDeckInfo() (string, *dbus.Error) {
 }
 
 func (StreamDeckDBus) GetConfig() (string, *dbus.Error) {
-	configString, err := json.Marshal(config)
+	merged, err := mergedConfig()
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	configString, err := json.Marshal(merged)
 	if err != nil {
 		return "", dbus.MakeFailedError(err)
 	}
@@ -98,6 +110,40 @@ func (StreamDeckDBus) GetModules() (string, *dbus.Error) {
 	return string(modulesString), nil
 }
 
+func (StreamDeckDBus) GetPageProviders() (string, *dbus.Error) {
+	providersString, err := json.Marshal(AvailablePageProviders())
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return string(providersString), nil
+}
+
+func (StreamDeckDBus) GetApplicationProviders() (string, *dbus.Error) {
+	providersString, err := json.Marshal(AvailableApplicationProviders())
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return string(providersString), nil
+}
+
+func (StreamDeckDBus) GetActiveApplication() (string, *dbus.Error) {
+	contextString, err := json.Marshal(applicationManager.GetContext())
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return string(contextString), nil
+}
+
+func (StreamDeckDBus) SetActiveApplication(contextString string) *dbus.Error {
+	var context ApplicationContext
+	err := json.Unmarshal([]byte(contextString), &context)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	setApplicationContext(context)
+	return nil
+}
+
 func (StreamDeckDBus) PressButton(serial string, keyIndex int) *dbus.Error {
 	dev, ok := Devs[serial]
 	if !ok || !dev.IsOpen() {
@@ -105,10 +151,44 @@ func (StreamDeckDBus) PressButton(serial string, keyIndex int) *dbus.Error {
 	}
 	dev.InputManager().HandleKeyInput(&dev.Config().Pages[dev.PageManager().GetPage()].Keys[keyIndex], streamdeck.InputEvent{
 		EventType: streamdeck.KEY_PRESS,
+		Index:     uint8(keyIndex),
 	})
 	return nil
 }
 
+func (StreamDeckDBus) SetKeyState(serial string, page int, keyIndex int, state int) *dbus.Error {
+	dev, ok := Devs[serial]
+	if !ok || !dev.IsOpen() {
+		return dbus.MakeFailedError(errors.New("Can't find connected device: " + serial))
+	}
+	pages := dev.Config().Pages
+	if page < 0 || page >= len(pages) || keyIndex < 0 || keyIndex >= len(pages[page].Keys) {
+		return dbus.MakeFailedError(errors.New("key does not exist"))
+	}
+	err := dev.MultiStateKeys().Set(keyIndex, page, keyApplication(&pages[page].Keys[keyIndex]), state)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (StreamDeckDBus) SetInputLock(serial string, locked bool) *dbus.Error {
+	dev, ok := Devs[serial]
+	if !ok {
+		return dbus.MakeFailedError(errors.New("Can't find device: " + serial))
+	}
+	dev.InputManager().SetInputLocked(locked)
+	return nil
+}
+
+func (StreamDeckDBus) FireEvent(name string) *dbus.Error {
+	if name == "" {
+		return dbus.MakeFailedError(errors.New("event name is empty"))
+	}
+	fireRules(ruleEvent{kind: RuleCustom, name: name})
+	return nil
+}
+
 func (StreamDeckDBus) GetHandlerExample(serial string, keyString string) (string, *dbus.Error) {
 	var key *api.KeyConfigV3
 	err := json.Unmarshal([]byte(keyString), &key)
@@ -235,6 +315,26 @@ func (StreamDeckDBus) GetKnobHandlerExample(serial string, keyString string) (st
 	}
 }
 
+var applicationSignalOnce sync.Once
+
+// attachApplicationSignal emits ActiveApplicationChanged for every focus change, it is only
+// attached once, as InitDBUS runs again whenever the connection is restarted
+func attachApplicationSignal() {
+	applicationSignalOnce.Do(func() {
+		applicationManager.AttachListener(func(context ApplicationContext) {
+			if conn == nil {
+				return
+			}
+			contextString, err := json.Marshal(context)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			conn.Emit("/com/unixstreamdeck/streamdeckd", "com.unixstreamdeck.streamdeckd.ActiveApplicationChanged", string(contextString))
+		})
+	})
+}
+
 func EmitPage(dev IVirtualDev, page int) {
 	if conn != nil {
 		conn.Emit("/com/unixstreamdeck/streamdeckd", "com.unixstreamdeck.streamdeckd.Page", dev.Serial(), page)
@@ -0,0 +1,323 @@
+package streamdeckd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const dbusCallTimeout = 10 * time.Second
+
+// DBusCallV1 calls a method on the session or system bus
+type DBusCallV1 struct {
+	// Bus is session (the default) or system
+	Bus         string      `json:"bus,omitempty"`
+	Destination string      `json:"destination"`
+	Path        string      `json:"path"`
+	Interface   string      `json:"interface"`
+	Member      string      `json:"member"`
+	Args        []DBusArgV1 `json:"args,omitempty"`
+	// ShowReply draws the method's reply over the key until the page changes
+	ShowReply bool `json:"show_reply,omitempty"`
+}
+
+// DBusArgV1 is an argument to a method, Type is its D-Bus signature, e.g. s, u, as or a{sv},
+// and Value its JSON value. Variants take either a plain string, bool or number, or another
+// DBusArgV1 to set the type of the value they hold
+type DBusArgV1 struct {
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+func (c *DBusCallV1) bus() (*dbus.Conn, error) {
+	switch c.Bus {
+	case "", "session":
+		if conn == nil {
+			return nil, errors.New("not connected to the session bus")
+		}
+		return conn, nil
+	case "system":
+		return dbus.SystemBus()
+	default:
+		return nil, fmt.Errorf("unknown bus %s", c.Bus)
+	}
+}
+
+// Call calls the method and waits for its reply
+func (c *DBusCallV1) Call() ([]any, error) {
+	bus, err := c.bus()
+	if err != nil {
+		return nil, err
+	}
+	args := make([]any, len(c.Args))
+	for i, arg := range c.Args {
+		args[i], err = arg.dbusValue()
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dbusCallTimeout)
+	defer cancel()
+	method := c.Member
+	if c.Interface != "" {
+		method = c.Interface + "." + c.Member
+	}
+	call := bus.Object(c.Destination, dbus.ObjectPath(c.Path)).CallWithContext(ctx, method, 0, args...)
+	return call.Body, call.Err
+}
+
+// UnmarshalJSON checks the argument can be sent as its type when the config is loaded, rather
+// than when the action runs
+func (a *DBusArgV1) UnmarshalJSON(data []byte) error {
+	type dbusArg DBusArgV1
+	var arg dbusArg
+	err := json.Unmarshal(data, &arg)
+	if err != nil {
+		return err
+	}
+	*a = DBusArgV1(arg)
+	_, err = a.dbusValue()
+	if err != nil {
+		return fmt.Errorf("D-Bus argument %v of type %q: %w", a.Value, a.Type, err)
+	}
+	return nil
+}
+
+func (a *DBusArgV1) dbusValue() (any, error) {
+	// an empty signature parses, but isn't a type
+	if a.Type == "" {
+		return nil, errors.New("arguments need a type")
+	}
+	_, err := dbus.ParseSignature(a.Type)
+	if err != nil {
+		return nil, err
+	}
+	t, err := dbusType(a.Type)
+	if err != nil {
+		return nil, err
+	}
+	if dbus.SignatureOfType(t).String() != a.Type {
+		return nil, fmt.Errorf("%s is not a single type", a.Type)
+	}
+	return dbusValue(a.Type, a.Value)
+}
+
+// dbusType returns the Go type godbus marshals as sig
+func dbusType(sig string) (reflect.Type, error) {
+	if sig == "" {
+		return nil, errors.New("missing type")
+	}
+	switch sig[0] {
+	case 'y':
+		return reflect.TypeFor[byte](), nil
+	case 'b':
+		return reflect.TypeFor[bool](), nil
+	case 'n':
+		return reflect.TypeFor[int16](), nil
+	case 'q':
+		return reflect.TypeFor[uint16](), nil
+	case 'i':
+		return reflect.TypeFor[int32](), nil
+	case 'u':
+		return reflect.TypeFor[uint32](), nil
+	case 'x':
+		return reflect.TypeFor[int64](), nil
+	case 't':
+		return reflect.TypeFor[uint64](), nil
+	case 'd':
+		return reflect.TypeFor[float64](), nil
+	case 's':
+		return reflect.TypeFor[string](), nil
+	case 'o':
+		return reflect.TypeFor[dbus.ObjectPath](), nil
+	case 'g':
+		return reflect.TypeFor[dbus.Signature](), nil
+	case 'v':
+		return reflect.TypeFor[dbus.Variant](), nil
+	case 'a':
+		if sig[1] == '{' {
+			keyType, err := dbusType(sig[2:3])
+			if err != nil {
+				return nil, err
+			}
+			valueType, err := dbusType(sig[3 : len(sig)-1])
+			if err != nil {
+				return nil, err
+			}
+			return reflect.MapOf(keyType, valueType), nil
+		}
+		elemType, err := dbusType(sig[1:])
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elemType), nil
+	default:
+		return nil, fmt.Errorf("arguments of type %s are not supported", sig)
+	}
+}
+
+func dbusValue(sig string, value any) (any, error) {
+	t, err := dbusType(sig)
+	if err != nil {
+		return nil, err
+	}
+	switch t.Kind() {
+	case reflect.Uint8, reflect.Int16, reflect.Uint16, reflect.Int32, reflect.Uint32, reflect.Int64, reflect.Uint64, reflect.Float64:
+		n, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a number", value)
+		}
+		v := reflect.New(t).Elem()
+		if t.Kind() != reflect.Float64 && n != math.Trunc(n) {
+			return nil, fmt.Errorf("%v is not a whole number", value)
+		}
+		switch {
+		case t.Kind() == reflect.Float64:
+			v.SetFloat(n)
+		case v.CanInt():
+			if v.OverflowInt(int64(n)) {
+				return nil, fmt.Errorf("%v is out of range for %s", value, sig)
+			}
+			v.SetInt(int64(n))
+		default:
+			if n < 0 || v.OverflowUint(uint64(n)) {
+				return nil, fmt.Errorf("%v is out of range for %s", value, sig)
+			}
+			v.SetUint(uint64(n))
+		}
+		return v.Interface(), nil
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a boolean", value)
+		}
+		return b, nil
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a string", value)
+		}
+		switch sig {
+		case "o":
+			return dbus.ObjectPath(s), nil
+		case "g":
+			return dbus.ParseSignature(s)
+		}
+		return s, nil
+	case reflect.Slice:
+		values, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%v is not an array", value)
+		}
+		slice := reflect.MakeSlice(t, 0, len(values))
+		for _, value := range values {
+			v, err := dbusValue(sig[1:], value)
+			if err != nil {
+				return nil, err
+			}
+			slice = reflect.Append(slice, reflect.ValueOf(v))
+		}
+		return slice.Interface(), nil
+	case reflect.Map:
+		values, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%v is not an object", value)
+		}
+		m := reflect.MakeMapWithSize(t, len(values))
+		for key, value := range values {
+			k, err := dbusMapKey(sig[2:3], key)
+			if err != nil {
+				return nil, err
+			}
+			v, err := dbusValue(sig[3:len(sig)-1], value)
+			if err != nil {
+				return nil, err
+			}
+			m.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
+		}
+		return m.Interface(), nil
+	default:
+		return dbusVariant(value)
+	}
+}
+
+// dbusMapKey converts a JSON object key, which is always a string, to the key type of a dict
+func dbusMapKey(sig string, key string) (any, error) {
+	if sig == "s" || sig == "o" || sig == "g" {
+		return dbusValue(sig, key)
+	}
+	if sig == "b" {
+		return dbusValue(sig, key == "true")
+	}
+	var n float64
+	_, err := fmt.Sscan(key, &n)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a number", key)
+	}
+	return dbusValue(sig, n)
+}
+
+func dbusVariant(value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		sig, ok := v["type"].(string)
+		if !ok {
+			return nil, errors.New("variants holding objects need a type and value")
+		}
+		arg := DBusArgV1{Type: sig, Value: v["value"]}
+		inner, err := arg.dbusValue()
+		if err != nil {
+			return nil, err
+		}
+		return dbus.MakeVariant(inner), nil
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32 {
+			return dbus.MakeVariant(int32(v)), nil
+		}
+		return dbus.MakeVariant(v), nil
+	case string, bool:
+		return dbus.MakeVariant(v), nil
+	default:
+		return nil, fmt.Errorf("the type of variant %v can't be guessed, give its type and value", value)
+	}
+}
+
+// formatDBusReply turns a method's reply into text for a key
+func formatDBusReply(body []any) string {
+	parts := make([]string, len(body))
+	for i, value := range body {
+		parts[i] = formatDBusValue(value)
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatDBusValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case dbus.ObjectPath:
+		return string(v)
+	case dbus.Variant:
+		return formatDBusValue(v.Value())
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (im *InputManager) callDBus(call *DBusCallV1, keyIndex int) {
+	reply, err := call.Call()
+	if err != nil {
+		im.vdev.Logger().Println("[ERROR] D-Bus call to", call.Destination, call.Member, "failed:", err)
+		return
+	}
+	if call.ShowReply && keyIndex >= 0 {
+		im.setFeedbackOverlay(im.drawOutput(formatDBusReply(reply)), keyIndex)
+	}
+}
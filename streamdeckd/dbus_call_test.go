@@ -0,0 +1,78 @@
+package streamdeckd
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestDBusArgValue(t *testing.T) {
+	tests := []struct {
+		sig     string
+		value   string
+		want    any
+		wantErr bool
+	}{
+		{sig: "s", value: `"hello"`, want: "hello"},
+		{sig: "b", value: `true`, want: true},
+		{sig: "y", value: `255`, want: byte(255)},
+		{sig: "i", value: `-5`, want: int32(-5)},
+		{sig: "u", value: `7`, want: uint32(7)},
+		{sig: "d", value: `1.5`, want: 1.5},
+		{sig: "o", value: `"/org/example"`, want: dbus.ObjectPath("/org/example")},
+		{sig: "as", value: `["a","b"]`, want: []string{"a", "b"}},
+		{sig: "a{su}", value: `{"a":1}`, want: map[string]uint32{"a": 1}},
+		{sig: "a{us}", value: `{"2":"b"}`, want: map[uint32]string{2: "b"}},
+		{sig: "v", value: `"text"`, want: dbus.MakeVariant("text")},
+		{sig: "v", value: `3`, want: dbus.MakeVariant(int32(3))},
+		{sig: "v", value: `{"type":"t","value":3}`, want: dbus.MakeVariant(uint64(3))},
+		{sig: "a{sv}", value: `{"volume":0.5}`, want: map[string]dbus.Variant{"volume": dbus.MakeVariant(0.5)}},
+		{sig: "", value: `"x"`, wantErr: true},
+		{sig: "a", value: `[]`, wantErr: true},
+		{sig: "ss", value: `"x"`, wantErr: true},
+		{sig: "(si)", value: `["x",1]`, wantErr: true},
+		{sig: "y", value: `256`, wantErr: true},
+		{sig: "u", value: `-1`, wantErr: true},
+		{sig: "i", value: `1.5`, wantErr: true},
+		{sig: "s", value: `1`, wantErr: true},
+		{sig: "as", value: `[1]`, wantErr: true},
+		{sig: "v", value: `{"type":"","value":3}`, wantErr: true},
+		{sig: "v", value: `{"value":3}`, wantErr: true},
+		{sig: "v", value: `null`, wantErr: true},
+	}
+	for _, test := range tests {
+		arg := DBusArgV1{Type: test.sig}
+		err := json.Unmarshal([]byte(test.value), &arg.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := arg.dbusValue()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s %s: got %#v, want an error", test.sig, test.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s: %v", test.sig, test.value, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s %s: got %#v, want %#v", test.sig, test.value, got, test.want)
+		}
+	}
+}
+
+func TestDBusArgCheckedOnLoad(t *testing.T) {
+	_, err := parseConfigExt([]byte(`{"decks":[{"pages":[{"keys":[{"application":{"":{"dbus_call":{"destination":"a","path":"/","member":"m","args":[{"type":"","value":"x"}]}}}}]}]}]}`))
+	if err == nil {
+		t.Error("config with a D-Bus argument that has no type was loaded")
+	}
+	ext, err := parseConfigExt([]byte(`{"decks":[{"pages":[{"keys":[{"application":{"":{"dbus_call":{"destination":"a","path":"/","member":"m","args":[{"type":"u","value":3}]}}}}]}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arg := ext.Decks[0].Pages[0].Keys[0].Application[""].DBusCall.Args[0]; arg.Type != "u" || arg.Value != 3.0 {
+		t.Errorf("argument loaded as %+v", arg)
+	}
+}
@@ -0,0 +1,206 @@
+package streamdeckd
+
+import (
+	"sync"
+
+	"github.com/unix-streamdeck/api/v2"
+)
+
+// PageProvider generates the keys of a page at runtime, callback should be called with the
+// full list of items every time it changes, the daemon takes care of splitting them across pages
+type PageProvider interface {
+	api.VisualHandler
+	Start(fields map[string]any, info api.StreamDeckInfoV1, callback func(items []*api.KeyConfigV3))
+}
+
+type IDynamicPager interface {
+	Open(provider string, fields map[string]any)
+	Close()
+	Slot() int
+	AttachPageChangeListener()
+}
+
+type DynamicPager struct {
+	vdev       IVirtualDev
+	mu         sync.Mutex
+	provider   PageProvider
+	items      []*api.KeyConfigV3
+	offset     int
+	slot       int
+	returnPage int
+}
+
+type dynamicPageNavigator struct {
+	pager *DynamicPager
+	delta int
+}
+
+func (n *dynamicPageNavigator) Input(_ map[string]any, _ api.HandlerType, _ api.StreamDeckInfoV1, _ api.InputEvent) {
+	n.pager.turn(n.delta)
+}
+
+func (dp *DynamicPager) Open(name string, fields map[string]any) {
+	newProvider, ok := pageProviders[name]
+	if !ok {
+		dp.vdev.Logger().Println("Could not find page provider:", name)
+		return
+	}
+
+	dp.Close()
+
+	dp.mu.Lock()
+	provider := newProvider()
+	provider.SetRunning(true)
+	dp.provider = provider
+	dp.items = nil
+	dp.offset = 0
+	dp.returnPage = dp.vdev.PageManager().GetPage()
+	pages := dp.vdev.Config().Pages
+	dp.slot = len(pages)
+	// a page from an earlier generated page may still be cached in the slot
+	dp.vdev.PageCache().ClearPage(dp.slot)
+	dp.vdev.SetPages(append(pages[:dp.slot:dp.slot], dp.buildPage()))
+	dp.mu.Unlock()
+
+	dp.vdev.Logger().Printf("Opened generated page %s\n", name)
+	dp.vdev.PageManager().SetPage(dp.slot)
+
+	go provider.Start(fields, *dp.vdev.SdInfo(), func(items []*api.KeyConfigV3) {
+		dp.mu.Lock()
+		defer dp.mu.Unlock()
+		if dp.provider != provider {
+			return
+		}
+		dp.items = items
+		if dp.offset >= len(items) {
+			dp.offset = 0
+		}
+		dp.updatePage()
+	})
+}
+
+func (dp *DynamicPager) Close() {
+	dp.mu.Lock()
+	provider := dp.provider
+	if provider == nil {
+		dp.mu.Unlock()
+		return
+	}
+	dp.provider = nil
+	dp.items = nil
+	dp.vdev.HandlerPruner().StopPageHandlers(dp.slot)
+	dp.vdev.PageCache().ClearPage(dp.slot)
+	pages := dp.vdev.Config().Pages
+	if dp.slot < len(pages) {
+		dp.vdev.SetPages(pages[:dp.slot])
+	}
+	dp.slot = -1
+	dp.mu.Unlock()
+
+	// stopped outside the lock, as the provider may be blocked delivering items
+	if provider.IsRunning() {
+		provider.Stop()
+	}
+}
+
+// Slot returns the page the generated page is shown on, or -1 if none is open
+func (dp *DynamicPager) Slot() int {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	return dp.slot
+}
+
+func (dp *DynamicPager) AttachPageChangeListener() {
+	dp.vdev.PageManager().AttachListener(func(newPage, previousPage int) {
+		dp.mu.Lock()
+		leaving := dp.provider != nil && previousPage == dp.slot && newPage != dp.slot
+		dp.mu.Unlock()
+		if leaving {
+			dp.Close()
+		}
+	})
+}
+
+func (dp *DynamicPager) turn(delta int) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	if dp.provider == nil {
+		return
+	}
+	offset := dp.offset + delta*dp.itemsPerPage()
+	if offset < 0 || offset >= len(dp.items) {
+		return
+	}
+	dp.offset = offset
+	dp.updatePage()
+}
+
+func (dp *DynamicPager) updatePage() {
+	pages := dp.vdev.Config().Pages
+	if dp.slot < 0 || dp.slot >= len(pages) {
+		return
+	}
+	dp.vdev.HandlerPruner().StopPageHandlers(dp.slot)
+	dp.vdev.PageCache().ClearPage(dp.slot)
+	pages[dp.slot] = dp.buildPage()
+	if dp.vdev.PageManager().GetPage() == dp.slot {
+		dp.vdev.PageManager().Refresh()
+	}
+}
+
+func (dp *DynamicPager) keyCount() int {
+	return dp.vdev.SdInfo().Cols * dp.vdev.SdInfo().Rows
+}
+
+// itemsPerPage leaves room for the back key, and the previous and next keys if the items
+// don't fit on a single page
+func (dp *DynamicPager) itemsPerPage() int {
+	keyCount := dp.keyCount()
+	if len(dp.items) < keyCount {
+		return keyCount - 1
+	}
+	return max(keyCount-3, 1)
+}
+
+func (dp *DynamicPager) buildPage() api.PageV3 {
+	keyCount := dp.keyCount()
+	keys := make([]*api.KeyConfigV3, keyCount)
+	perPage := dp.itemsPerPage()
+
+	for i := 0; i < perPage && dp.offset+i < len(dp.items); i++ {
+		keys[i] = dp.items[dp.offset+i]
+	}
+
+	if perPage < keyCount-1 {
+		if dp.offset > 0 {
+			keys[keyCount-3] = &api.KeyConfigV3{
+				Text:             "<",
+				KeyHandler:       "DynamicPagePrevious",
+				KeyHandlerStruct: &dynamicPageNavigator{pager: dp, delta: -1},
+			}
+		}
+		if dp.offset+perPage < len(dp.items) {
+			keys[keyCount-2] = &api.KeyConfigV3{
+				Text:             ">",
+				KeyHandler:       "DynamicPageNext",
+				KeyHandlerStruct: &dynamicPageNavigator{pager: dp, delta: 1},
+			}
+		}
+	}
+
+	keys[keyCount-1] = &api.KeyConfigV3{
+		Text:       "Back",
+		SwitchPage: dp.returnPage + 1,
+	}
+
+	page := api.PageV3{}
+	for _, key := range keys {
+		if key == nil {
+			key = &api.KeyConfigV3{}
+		}
+		page.Keys = append(page.Keys, api.KeyV3{
+			Application: map[string]*api.KeyConfigV3{"": key},
+		})
+	}
+	return page
+}
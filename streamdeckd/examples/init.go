@@ -11,4 +11,5 @@ func RegisterBaseModules() {
 	streamdeckd.RegisterModule(RegisterToggle())
 	streamdeckd.RegisterModule(RegisterPlayerCtl())
 	streamdeckd.RegisterModule(RegisterVolume())
+	RegisterPlayersProvider()
 }
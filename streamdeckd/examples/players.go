@@ -0,0 +1,89 @@
+package examples
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/Endg4meZer0/go-mpris"
+	"github.com/godbus/dbus/v5"
+	"github.com/unix-streamdeck/api/v2"
+	"github.com/unix-streamdeck/streamdeckd/streamdeckd"
+)
+
+type PlayersProvider struct {
+	Running bool
+	Quit    chan bool
+	Client  *dbus.Conn
+}
+
+func (p *PlayersProvider) Start(fields map[string]any, info api.StreamDeckInfoV1, callback func(items []*api.KeyConfigV3)) {
+	p.Running = true
+	if p.Quit == nil {
+		p.Quit = make(chan bool)
+	}
+	var previous []string
+	for {
+		players, err := mpris.List(p.Client)
+		if err != nil {
+			log.Println(err)
+		} else if !slices.Equal(players, previous) {
+			previous = players
+			callback(p.items(players, fields))
+		}
+		select {
+		case <-p.Quit:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (p *PlayersProvider) items(players []string, fields map[string]any) []*api.KeyConfigV3 {
+	operation, ok := fields["operation"].(string)
+	if !ok {
+		operation = string(PlayPause)
+	}
+	var items []*api.KeyConfigV3
+	for _, name := range players {
+		player := mpris.New(p.Client, name)
+		if player.GetShortName() == "playerctld" {
+			continue
+		}
+		items = append(items, &api.KeyConfigV3{
+			IconHandler: "Playerctl",
+			KeyHandler:  "Playerctl",
+			SharedHandlerFields: map[string]any{
+				"player_name": player.GetShortName(),
+				"type":        string(Playback),
+			},
+			KeyHandlerFields: map[string]any{
+				"operation": operation,
+			},
+		})
+	}
+	return items
+}
+
+func (p *PlayersProvider) IsRunning() bool {
+	return p.Running
+}
+
+func (p *PlayersProvider) SetRunning(running bool) {
+	p.Running = running
+}
+
+func (p *PlayersProvider) Stop() {
+	p.Running = false
+	p.Quit <- true
+}
+
+func RegisterPlayersProvider() {
+	streamdeckd.RegisterPageProvider("Players", func() streamdeckd.PageProvider {
+		client, err := dbus.SessionBus()
+		if err != nil {
+			panic(err)
+		}
+		return &PlayersProvider{Client: client, Quit: make(chan bool)}
+	})
+}
@@ -122,18 +122,17 @@ func (c *ToggleHandler) Stop() {
 }
 
 func (t *ToggleHandler) Input(fields map[string]any, handlerType api.HandlerType, info api.StreamDeckInfoV1, event api.InputEvent) {
-	sharedStatus := fields["status"].(bool)
+	sharedStatus, _ := fields["status"].(bool)
 	index := "down_command"
 	if !sharedStatus {
 		index = "up_command"
 	}
-	command, ok := fields[index]
-	commandString := command.(string)
+	commandString, ok := fields[index].(string)
 	if !ok {
 		return
 	}
 	go func() {
-		cmd := exec.Command("/bin/sh", commandString)
+		cmd := exec.Command("/bin/sh", "-c", commandString)
 
 		if err := cmd.Start(); err != nil {
 			log.Println("There was a problem running ", commandString, ":", err)
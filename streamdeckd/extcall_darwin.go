@@ -12,15 +12,31 @@ import (
 
 var kb uinput.Keyboard
 
-func UpdateApplication() {
+var mouse uinput.Mouse
+
+func RegisterBuiltinApplicationProviders() {
 	log.Println("Application based configuration is not currently supported on macOS, due to the difficulty in getting the current active application, sorry :(")
 }
 
+func processExe(_ int) string {
+	return ""
+}
+
+func sessionKeyboardLayout() (string, error) {
+	return "", nil
+}
+
 func EnableVirtualKeyboard() {
 	defer HandlePanic(func() {
 		log.Println("VirtualKeyboard crash")
 	})
 	var err error
+	mouse, err = uinput.CreateMouse("/dev/uinput", []byte("streamdeckd mouse"))
+	if err != nil {
+		log.Println(err)
+	} else {
+		defer mouse.Close()
+	}
 	kb, err = uinput.CreateKeyboard("/dev/uinput", []byte("streamdeckd"))
 	if err != nil {
 		log.Println(err)
@@ -46,6 +62,7 @@ func InitDBUS() {
 	defer HandlePanic(reInitDBus)
 
 	sDbus = &StreamDeckDBus{}
+	attachApplicationSignal()
 	conn.ExportAll(sDbus, "/com/unixstreamdeck/streamdeckd", "com.unixstreamdeck.streamdeckd")
 	reply, err := conn.RequestName("com.unixstreamdeck.streamdeckd",
 		dbus.NameFlagDoNotQueue)
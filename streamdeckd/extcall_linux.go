@@ -3,129 +3,89 @@
 package streamdeckd
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bendahl/uinput"
 	"github.com/godbus/dbus/v5"
-	x "github.com/linuxdeepin/go-x11-client"
-	"golang.org/x/sys/unix"
 )
 
-var c *x.Conn
-
 var kb uinput.Keyboard
 
-func UpdateApplication() {
-	sessionType, found := unix.Getenv("XDG_SESSION_TYPE")
-
-	if found && sessionType == "x11" {
-		updateX11Application()
-		return
-	}
+var mouse uinput.Mouse
 
-	desktopSession, found := unix.Getenv("XDG_CURRENT_DESKTOP")
-	if !found {
-		log.Println("[WARN] Could not find XDG_CURRENT_DESKTOP env var, application based configuration is unavailable")
-	}
+// RegisterBuiltinApplicationProviders registers the providers that ship with streamdeckd, in
+// the order they are tried when the config doesn't list any
+func RegisterBuiltinApplicationProviders() {
+	// sway and i3 both speak the i3 IPC protocol, which reports focus changes without polling
+	RegisterApplicationProvider("i3", func() ApplicationProvider { return &I3Provider{} })
+	RegisterApplicationProvider("x11", func() ApplicationProvider { return &X11Provider{} })
 	// I get Wayland has security concerns at the core of it, but having to do stuff like this to get metadata about the focused application is insane
-	switch desktopSession {
-	case "Hyprland":
-		updateHyprlandApplication()
-	case "KDE":
-		updateKDEApplication()
-	default:
-		// if your favourite desktop session is not here, feel free to submit a PR, I just don't have time to test on all sessions
-		log.Println("[WARN] Desktop session:", desktopSession, "is unsupported, application based configuration is unavailable")
-	}
+	RegisterApplicationProvider("hyprland", func() ApplicationProvider { return &HyprlandProvider{} })
+	RegisterApplicationProvider("kde", func() ApplicationProvider { return &KDEProvider{} })
 }
 
-func setApplication(activePs string) {
-	activePs = strings.Trim(activePs, "\n")
-	applicationManager.SetApplication(activePs)
+func processExe(pid int) string {
+	exe, _ := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	return exe
 }
 
-type HyprlandActiveWindow struct {
-	Class string `json:"class"`
+type KDEProvider struct {
+	mu   sync.Mutex
+	stop chan struct{}
 }
 
-func updateHyprlandApplication() {
-	errCounter := 0
-	for {
-		out, err := exec.Command("/bin/sh", "-c", "hyprctl activewindow -j").Output()
-		if err != nil {
-			log.Println(err)
-			errCounter += 1
-			if errCounter == 10 {
-				log.Println("[WARN] Repeated error getting Hyprland active window, application based configuration is unavailable until streamdeckd restart")
-				return
-			}
-		} else {
-			errCounter = 0
-		}
-		var activeWindow HyprlandActiveWindow
-		err = json.Unmarshal(out, &activeWindow)
-		if err != nil {
-			log.Println(err)
-			errCounter += 1
-			if errCounter == 10 {
-				log.Println("[WARN] Repeated error getting Hyprland active window, application based configuration is unavailable until streamdeckd restart")
-				return
-			}
-		} else {
-			errCounter = 0
-		}
-		if activeWindow.Class != "" {
-			setApplication(activeWindow.Class)
-		}
-		time.Sleep(time.Duration((errCounter+1)*50) * time.Millisecond)
-	}
+func (p *KDEProvider) Available() bool {
+	return desktopIs("KDE")
 }
-func updateKDEApplication() {
-	errCounter := 0
+
+// Start polls kdotool for the focused window. kdotool fails while nothing has focus, or while
+// kwin restarts, so its errors are retried with a backoff, only a missing kdotool stops it
+func (p *KDEProvider) Start(contexts chan<- ApplicationContext) error {
+	stop := make(chan struct{})
+	p.mu.Lock()
+	p.stop = stop
+	p.mu.Unlock()
+	previous := ""
+	backoff := time.Second
 	for {
+		delay := 50 * time.Millisecond
 		out, err := exec.Command("/bin/sh", "-c", "kdotool getwindowclassname $(kdotool getactivewindow)").Output()
-		if err != nil {
-			log.Println(err)
-			errCounter += 1
-			if errCounter == 10 {
-				log.Println("[WARN] Repeated error getting KDE active window, application based configuration is unavailable until streamdeckd restart")
-				return
-			}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 127 {
+			return errors.New("kdotool is not installed")
+		} else if err != nil {
+			log.Println("[WARN] kdotool failed, retrying in", backoff, err)
+			delay = backoff
+			backoff = min(backoff*2, applicationProviderMaxBackoff)
 		} else {
-			errCounter = 0
+			backoff = time.Second
+			outString := strings.Trim(string(out), " \n")
+			if outString != "" && outString != previous {
+				previous = outString
+				contexts <- ApplicationContext{Class: outString}
+			}
 		}
-		outString := string(out)
-		outString = strings.Trim(outString, " \n")
-		if outString != "" {
-			setApplication(outString)
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(delay):
 		}
-		time.Sleep(time.Duration((errCounter+1)*50) * time.Millisecond)
 	}
 }
 
-func updateX11Application() {
-	c, _ = x.NewConn()
-	defer c.Close()
-	var activeWindow x.Window
-	for {
-		win, _ := x.GetInputFocus(c).Reply(c)
-		if activeWindow != win.Focus {
-			activeWindow = win.Focus
-			active, err := exec.Command("xdotool", "getactivewindow", "getwindowclassname").Output()
-			if err != nil {
-				active = []byte("")
-			}
-			activePs := string(active)
-			log.Println(activePs)
-			activePs = strings.Trim(activePs, "\n")
-			setApplication(activePs)
-		}
-		time.Sleep(50 * time.Millisecond)
+func (p *KDEProvider) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
 	}
 }
 
@@ -134,6 +94,12 @@ func EnableVirtualKeyboard() {
 		log.Println("VirtualKeyboard crash")
 	})
 	var err error
+	mouse, err = uinput.CreateMouse("/dev/uinput", []byte("streamdeckd mouse"))
+	if err != nil {
+		log.Println(err)
+	} else {
+		defer mouse.Close()
+	}
 	kb, err = uinput.CreateKeyboard("/dev/uinput", []byte("streamdeckd"))
 	if err != nil {
 		log.Println(err)
@@ -159,6 +125,7 @@ func InitDBUS() {
 	defer HandlePanic(reInitDBus)
 
 	sDbus = &StreamDeckDBus{}
+	attachApplicationSignal()
 	conn.ExportAll(sDbus, "/com/unixstreamdeck/streamdeckd", "com.unixstreamdeck.streamdeckd")
 	reply, err := conn.RequestName("com.unixstreamdeck.streamdeckd",
 		dbus.NameFlagDoNotQueue)
@@ -204,6 +171,11 @@ func (c *ScreensaverConnection) RegisterScreensaverActiveListener() {
 					deck.HandleScreenLockChange(locked)
 				}
 			}
+			if locked {
+				fireRules(ruleEvent{kind: RuleScreenLock})
+			} else {
+				fireRules(ruleEvent{kind: RuleScreenUnlock})
+			}
 		}
 	}
 }
@@ -3,6 +3,8 @@ package streamdeckd
 import (
 	"fmt"
 	"image"
+	"sync"
+	"time"
 
 	"github.com/unix-streamdeck/api/v2"
 )
@@ -10,12 +12,20 @@ import (
 type IForegrounder interface {
 	SetKnob(currentKnobConfig *api.KnobConfigV3, knobIndex int, page int, activeApp string)
 	SetKey(currentKeyConfig *api.KeyConfigV3, keyIndex int, page int, activeApp string)
+	Preload(page int)
 	AttachPageChangeListener()
 	AttachAppChangeListener()
 }
 
+// offPageFrameInterval is how often a handler kept running by the page cache may draw a frame
+// while its page isn't showing
+const offPageFrameInterval = time.Second
+
 type Foregrounder struct {
 	vdev IVirtualDev
+	mu   sync.Mutex
+	// pageChanged is closed when the page changes, to wake handlers held up by throttle
+	pageChanged chan struct{}
 }
 
 func (f *Foregrounder) SetKnob(currentKnobConfig *api.KnobConfigV3, knobIndex int, page int, activeApp string) {
@@ -24,7 +34,11 @@ func (f *Foregrounder) SetKnob(currentKnobConfig *api.KnobConfigV3, knobIndex in
 		go f.SetKnob(currentKnobConfig, knobIndex, page, activeApp)
 	})
 	if currentKnobConfig.LcdHandler != "" {
+		var lastFrame time.Time
 		f.setHandler(currentKnobConfig, api.LCD, knobIndex, activeApp, func(img image.Image) {
+			if !f.throttle(page, &lastFrame) {
+				return
+			}
 			if img.Bounds().Dx() != f.vdev.SdInfo().LcdWidth || img.Bounds().Dy() != f.vdev.SdInfo().LcdHeight {
 				img = api.ResizeImageWH(img, f.vdev.SdInfo().LcdWidth, f.vdev.SdInfo().LcdHeight)
 			}
@@ -32,9 +46,12 @@ func (f *Foregrounder) SetKnob(currentKnobConfig *api.KnobConfigV3, knobIndex in
 		})
 	}
 	if currentKnobConfig.LcdHandlerStruct == nil {
-		img := f.loadStaticImage(currentKnobConfig, f.vdev.SdInfo().LcdWidth, f.vdev.SdInfo().LcdHeight)
+		img := f.vdev.PageCache().Panel(knobIndex, page, activeApp)
+		if img == nil {
+			img = f.loadStaticImage(currentKnobConfig, f.vdev.SdInfo().LcdWidth, f.vdev.SdInfo().LcdHeight)
+		}
 		if img != nil {
-			f.vdev.SetKeyForeground(img, knobIndex, page)
+			f.vdev.SetPanelForeground(img, knobIndex, page)
 		}
 	}
 }
@@ -45,7 +62,11 @@ func (f *Foregrounder) SetKey(currentKeyConfig *api.KeyConfigV3, keyIndex int, p
 		go f.SetKey(currentKeyConfig, keyIndex, page, activeApp)
 	})
 	if currentKeyConfig.IconHandler != "" {
+		var lastFrame time.Time
 		f.setHandler(currentKeyConfig, api.KEY, keyIndex, activeApp, func(img image.Image) {
+			if !f.throttle(page, &lastFrame) {
+				return
+			}
 			if img.Bounds().Dx() != f.vdev.SdInfo().IconSize || img.Bounds().Dy() != f.vdev.SdInfo().IconSize {
 				img = api.ResizeImage(img, f.vdev.SdInfo().IconSize)
 			}
@@ -53,7 +74,24 @@ func (f *Foregrounder) SetKey(currentKeyConfig *api.KeyConfigV3, keyIndex int, p
 		})
 	}
 	if currentKeyConfig.IconHandlerStruct == nil {
-		img := f.loadStaticImage(currentKeyConfig, f.vdev.SdInfo().IconSize, f.vdev.SdInfo().IconSize)
+		if keyState := f.vdev.MultiStateKeys().Current(keyIndex, page, activeApp); keyState != nil {
+			img := f.loadStaticImage(keyState, f.vdev.SdInfo().IconSize, f.vdev.SdInfo().IconSize)
+			if img != nil {
+				f.vdev.SetKeyForeground(img, keyIndex, page)
+			}
+			return
+		}
+		if look := mqttKeyLook(f.vdev.Serial(), keyIndex, page, activeApp, currentKeyConfig); look != nil {
+			img := f.loadStaticImage(look, f.vdev.SdInfo().IconSize, f.vdev.SdInfo().IconSize)
+			if img != nil {
+				f.vdev.SetKeyForeground(img, keyIndex, page)
+			}
+			return
+		}
+		img := f.vdev.PageCache().Key(keyIndex, page, activeApp)
+		if img == nil {
+			img = f.loadStaticImage(currentKeyConfig, f.vdev.SdInfo().IconSize, f.vdev.SdInfo().IconSize)
+		}
 		if img != nil {
 			f.vdev.SetKeyForeground(img, keyIndex, page)
 		}
@@ -61,7 +99,12 @@ func (f *Foregrounder) SetKey(currentKeyConfig *api.KeyConfigV3, keyIndex int, p
 }
 
 func (f *Foregrounder) setHandler(foregroundActions api.ForegroundAndInputHandlerConfig, handlerType api.HandlerType, index int, activeApp string, callback func(img image.Image)) {
-	if foregroundActions.GetForegroundHandlerInstance() == nil {
+	existingHandler := foregroundActions.GetForegroundHandlerInstance()
+	if existingHandler != nil && existingHandler.IsRunning() && f.vdev.PageCache().KeepsHandlers() {
+		// kept running in the background by the page cache, its frames are already going to this page
+		return
+	}
+	if existingHandler == nil {
 		var handler api.ForegroundHandler
 		modules := AvailableModules()
 		for _, module := range modules {
@@ -116,8 +159,81 @@ func (f *Foregrounder) loadStaticImage(fa api.ForegroundActions, w, h int) image
 	return img
 }
 
+// Preload renders a page that isn't showing yet into the page cache, handlers are only
+// started if the page cache keeps them running
+func (f *Foregrounder) Preload(page int) {
+	pages := f.vdev.Config().Pages
+	if page < 0 || page >= len(pages) {
+		return
+	}
+	keepHandlers := f.vdev.PageCache().KeepsHandlers()
+	for i := range pages[page].Keys {
+		key := &pages[page].Keys[i]
+		key.ActiveApplication = keyApplication(key)
+		keyConfig, ok := key.Application[key.ActiveApplication]
+		if !ok || (keyConfig.IconHandler != "" && !keepHandlers) {
+			continue
+		}
+		if keyConfig.IconHandler == "" && f.vdev.PageCache().Key(i, page, key.ActiveApplication) != nil {
+			continue
+		}
+		go f.SetKey(keyConfig, i, page, key.ActiveApplication)
+	}
+	for i := range pages[page].Knobs {
+		knob := &pages[page].Knobs[i]
+		knob.ActiveApplication = knobApplication(knob)
+		knobConfig, ok := knob.Application[knob.ActiveApplication]
+		if !ok || (knobConfig.LcdHandler != "" && !keepHandlers) {
+			continue
+		}
+		if knobConfig.LcdHandler == "" && f.vdev.PageCache().Panel(i, page, knob.ActiveApplication) != nil {
+			continue
+		}
+		go f.SetKnob(knobConfig, i, page, knob.ActiveApplication)
+	}
+}
+
+// throttle is called with each frame a handler draws for page, and reports if the frame should
+// be used. Handlers on a page that isn't showing are held up here so they only draw a frame every
+// offPageFrameInterval, or dropped if the page cache won't keep the frame
+func (f *Foregrounder) throttle(page int, lastFrame *time.Time) bool {
+	if f.vdev.PageManager().GetPage() == page {
+		*lastFrame = time.Now()
+		return true
+	}
+	if !f.vdev.PageCache().Wants(page) {
+		return false
+	}
+	if wait := offPageFrameInterval - time.Since(*lastFrame); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-f.pageChange():
+			timer.Stop()
+		}
+	}
+	*lastFrame = time.Now()
+	return f.vdev.PageManager().GetPage() == page || f.vdev.PageCache().Wants(page)
+}
+
+func (f *Foregrounder) pageChange() chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pageChanged == nil {
+		f.pageChanged = make(chan struct{})
+	}
+	return f.pageChanged
+}
+
 func (f *Foregrounder) AttachPageChangeListener() {
 	f.vdev.PageManager().AttachListener(func(newPage, _ int) {
+		f.mu.Lock()
+		if f.pageChanged != nil {
+			close(f.pageChanged)
+			f.pageChanged = nil
+		}
+		f.mu.Unlock()
+
 		currentPage := f.vdev.Config().Pages[newPage]
 
 		for i, _ := range currentPage.Keys {
@@ -130,13 +246,7 @@ func (f *Foregrounder) AttachPageChangeListener() {
 				f.vdev.Logger().Println(fmt.Sprintf("Setting empty application on key: %d on page: %d", i, newPage))
 				SaveConfig()
 			}
-			_, keyHasApp := key.Application[applicationManager.GetApplication()]
-			if key.ActiveApplication != "" && !keyHasApp {
-				key.ActiveApplication = ""
-			}
-			if keyHasApp {
-				key.ActiveApplication = applicationManager.GetApplication()
-			}
+			key.ActiveApplication = keyApplication(key)
 			go f.SetKey(key.Application[key.ActiveApplication], i, newPage, key.ActiveApplication)
 		}
 		for i, _ := range currentPage.Knobs {
@@ -149,48 +259,30 @@ func (f *Foregrounder) AttachPageChangeListener() {
 				f.vdev.Logger().Println(fmt.Sprintf("Setting empty application on knob: %d on page: %d", i, newPage))
 				SaveConfig()
 			}
-			_, knobHasApp := knob.Application[applicationManager.GetApplication()]
-			if knob.ActiveApplication != "" && !knobHasApp {
-				knob.ActiveApplication = ""
-			}
-			if knobHasApp {
-				knob.ActiveApplication = applicationManager.GetApplication()
-			}
+			knob.ActiveApplication = knobApplication(knob)
 			go f.SetKnob(knob.Application[knob.ActiveApplication], i, newPage, knob.ActiveApplication)
 		}
 	})
 }
 
 func (f *Foregrounder) AttachAppChangeListener() {
-	applicationManager.AttachListener(func(application string) {
+	applicationManager.AttachListener(func(_ ApplicationContext) {
 		page := f.vdev.Config().Pages[f.vdev.PageManager().GetPage()]
 		for i := range page.Keys {
 			key := &page.Keys[i]
-			_, keyHasApp := key.Application[application]
 			activeApp := key.ActiveApplication
-			if key.Application[key.ActiveApplication].KeyHold != 0 && (keyHasApp || key.ActiveApplication != "") {
-				kb.KeyUp(key.Application[key.ActiveApplication].KeyHold)
-			}
-			if key.ActiveApplication != "" && !keyHasApp {
-				key.ActiveApplication = ""
-			}
-			if keyHasApp {
-				key.ActiveApplication = application
-			}
+			key.ActiveApplication = keyApplication(key)
 			if key.ActiveApplication != activeApp {
+				if keyConfig, ok := key.Application[activeApp]; ok && keyConfig.KeyHold != 0 {
+					kb.KeyUp(keyConfig.KeyHold)
+				}
 				go f.SetKey(key.Application[key.ActiveApplication], i, f.vdev.PageManager().GetPage(), key.ActiveApplication)
 			}
 		}
 		for i := range page.Knobs {
 			knob := &page.Knobs[i]
-			_, keyHasApp := knob.Application[application]
 			activeApp := knob.ActiveApplication
-			if knob.ActiveApplication != "" && !keyHasApp {
-				knob.ActiveApplication = ""
-			}
-			if keyHasApp {
-				knob.ActiveApplication = application
-			}
+			knob.ActiveApplication = knobApplication(knob)
 			if knob.ActiveApplication != activeApp {
 				go f.SetKnob(knob.Application[knob.ActiveApplication], i, f.vdev.PageManager().GetPage(), knob.ActiveApplication)
 			}
@@ -9,6 +9,7 @@ import (
 type IHandlerPruner interface {
 	OnPageChange()
 	OnAppSwitch()
+	StopPageHandlers(pageNo int)
 	StopAllHandlers()
 }
 
@@ -18,14 +19,28 @@ type HandlerPruner struct {
 
 func (hp *HandlerPruner) OnPageChange() {
 	hp.vdev.PageManager().AttachListener(func(newPage, previousPage int) {
-		if newPage != previousPage {
-			hp.stopPageHandlers(previousPage)
+		if newPage == previousPage {
+			return
+		}
+		if !hp.vdev.PageCache().KeepsHandlers() {
+			hp.StopPageHandlers(previousPage)
+			return
+		}
+		// the page cache keeps the pages either side of the current one running
+		for page := previousPage - 1; page <= previousPage+1; page++ {
+			if page < newPage-1 || page > newPage+1 {
+				hp.StopPageHandlers(page)
+			}
 		}
 	})
 }
 
-func (hp *HandlerPruner) stopPageHandlers(pageNo int) {
-	page := hp.vdev.Config().Pages[pageNo]
+func (hp *HandlerPruner) StopPageHandlers(pageNo int) {
+	pages := hp.vdev.Config().Pages
+	if pageNo < 0 || pageNo >= len(pages) {
+		return
+	}
+	page := pages[pageNo]
 
 	if page.TouchPanelBackgroundHandler != nil {
 		go hp.stopHandler(page.TouchPanelBackgroundHandler, page.TouchPanelBackground, fmt.Sprintf("page %d background", pageNo))
@@ -72,19 +87,11 @@ func (hp *HandlerPruner) stopPageHandlers(pageNo int) {
 }
 
 func (hp *HandlerPruner) OnAppSwitch() {
-	applicationManager.AttachListener(func(activeApp string) {
+	applicationManager.AttachListener(func(_ ApplicationContext) {
 		page := hp.vdev.Config().Pages[hp.vdev.PageManager().GetPage()]
 
 		for i, key := range page.Keys {
-			_, hasNewActiveApp := key.Application[activeApp]
-
-			var newActiveApp string
-
-			if hasNewActiveApp {
-				newActiveApp = activeApp
-			} else {
-				newActiveApp = ""
-			}
+			newActiveApp := keyApplication(&key)
 
 			for appName, keyConfig := range key.Application {
 				if appName == newActiveApp {
@@ -102,16 +109,7 @@ func (hp *HandlerPruner) OnAppSwitch() {
 		}
 
 		for i, knob := range page.Knobs {
-
-			_, hasNewActiveApp := knob.Application[activeApp]
-
-			var newActiveApp string
-
-			if hasNewActiveApp {
-				newActiveApp = activeApp
-			} else {
-				newActiveApp = ""
-			}
+			newActiveApp := knobApplication(&knob)
 
 			for appName, knobConfig := range knob.Application {
 				if appName == newActiveApp {
@@ -138,7 +136,7 @@ func (hp *HandlerPruner) StopAllHandlers() {
 		hp.vdev.Config().TouchPanelBackgroundHandler.Stop()
 	}
 	for page := range hp.vdev.Config().Pages {
-		hp.stopPageHandlers(page)
+		hp.StopPageHandlers(page)
 	}
 }
 
@@ -9,6 +9,8 @@ import (
 
 var modules []api.Module
 
+var pageProviders = map[string]func() PageProvider{}
+
 func AvailableModules() []api.Module {
 	return modules
 }
@@ -28,6 +30,23 @@ func RegisterModule(m api.Module) {
 	modules = append(modules, m)
 }
 
+func RegisterPageProvider(name string, newProvider func() PageProvider) {
+	if _, ok := pageProviders[name]; ok {
+		log.Println("Page provider already loaded: " + name)
+		return
+	}
+	log.Println("Loaded page provider " + name)
+	pageProviders[name] = newProvider
+}
+
+func AvailablePageProviders() []string {
+	var names []string
+	for name := range pageProviders {
+		names = append(names, name)
+	}
+	return names
+}
+
 func LoadModule(path string) {
 	plug, err := plugin.Open(path)
 	if err != nil {
@@ -45,7 +64,29 @@ func LoadModule(path string) {
 		log.Println("Failed to load module: " + path)
 		return
 	}
-	RegisterModule(modMethod())
+	module := modMethod()
+	RegisterModule(module)
+
+	// page and application providers are optional, and can't be part of api.Module without every plugin depending on streamdeckd
+	providerSym, err := plug.Lookup("NewPageProvider")
+	if err == nil {
+		newProvider, ok := providerSym.(func() PageProvider)
+		if ok {
+			RegisterPageProvider(module.Name, newProvider)
+		} else {
+			log.Println("Failed to load page provider: " + path)
+		}
+	}
+
+	applicationProviderSym, err := plug.Lookup("NewApplicationProvider")
+	if err == nil {
+		newApplicationProvider, ok := applicationProviderSym.(func() ApplicationProvider)
+		if ok {
+			RegisterApplicationProvider(module.Name, newApplicationProvider)
+		} else {
+			log.Println("Failed to load application provider: " + path)
+		}
+	}
 }
 
 func UnmountHandlers() {
@@ -0,0 +1,154 @@
+package streamdeckd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultHTTPTimeout = 10 * time.Second
+
+// HTTPRequestV1 sends a request, such as to a webhook, the key flashes green when it gets a 2xx
+// response and red otherwise
+type HTTPRequestV1 struct {
+	// Method defaults to GET, or POST when there is a body
+	Method  string            `json:"method,omitempty"`
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is a text/template, given the deck's Serial, Page and Key, and the focused
+	// Application
+	Body string `json:"body,omitempty"`
+	// Timeout is in ms, defaults to 10 seconds
+	Timeout int `json:"timeout,omitempty"`
+	// JSONPath picks a value out of a JSON response to show on the key, as dot separated object
+	// keys and array indexes, e.g. state or data.items.0.name
+	JSONPath string `json:"json_path,omitempty"`
+}
+
+// HTTPTemplateData is what an HTTPRequestV1's body template is given
+type HTTPTemplateData struct {
+	Serial      string
+	Page        int
+	Key         int
+	Application ApplicationContext
+}
+
+func (r *HTTPRequestV1) method() string {
+	if r.Method != "" {
+		return strings.ToUpper(r.Method)
+	}
+	if r.Body != "" {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+// Do sends the request with client, returning the response's status code and body
+func (r *HTTPRequestV1) Do(client *http.Client, data HTTPTemplateData) (int, []byte, error) {
+	var body io.Reader
+	if r.Body != "" {
+		tmpl, err := template.New("body").Parse(r.Body)
+		if err != nil {
+			return 0, nil, err
+		}
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, data)
+		if err != nil {
+			return 0, nil, err
+		}
+		body = &buf
+	}
+	timeout := defaultHTTPTimeout
+	if r.Timeout > 0 {
+		timeout = time.Duration(r.Timeout) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, r.method(), r.Url, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, respBody, err
+}
+
+// lookupJSONPath returns the value at path in a JSON document as text
+func lookupJSONPath(document []byte, path string) (string, error) {
+	var value any
+	err := json.Unmarshal(document, &value)
+	if err != nil {
+		return "", err
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			next, ok := v[part]
+			if !ok {
+				return "", fmt.Errorf("%s not found in response", part)
+			}
+			value = next
+		case []any:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(v) {
+				return "", fmt.Errorf("%s is not an index of the array in response", part)
+			}
+			value = v[index]
+		default:
+			return "", fmt.Errorf("%s not found in response", part)
+		}
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	default:
+		text, err := json.Marshal(v)
+		return string(text), err
+	}
+}
+
+func (im *InputManager) sendHTTPRequest(request *HTTPRequestV1, keyIndex int) {
+	page := im.vdev.PageManager().GetPage()
+	status, body, err := request.Do(http.DefaultClient, HTTPTemplateData{
+		Serial:      im.vdev.Serial(),
+		Page:        page,
+		Key:         keyIndex,
+		Application: applicationManager.GetContext(),
+	})
+	success := err == nil && status >= 200 && status < 300
+	if err != nil {
+		im.vdev.Logger().Println("[ERROR] HTTP request to", request.Url, "failed:", err)
+	} else if !success {
+		im.vdev.Logger().Println("[ERROR] HTTP request to", request.Url, "returned", status)
+	}
+	if keyIndex < 0 || im.vdev.PageManager().GetPage() != page {
+		return
+	}
+	var result image.Image
+	if success && request.JSONPath != "" {
+		text, err := lookupJSONPath(body, request.JSONPath)
+		if err != nil {
+			im.vdev.Logger().Println("[ERROR] Could not read HTTP response from", request.Url, ":", err)
+		} else {
+			result = im.drawOutput(text)
+		}
+	}
+	im.showResult(keyIndex, page, true, success, result)
+}
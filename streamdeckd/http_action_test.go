@@ -0,0 +1,119 @@
+package streamdeckd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPRequestTemplateBody(t *testing.T) {
+	var method, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		contentType = r.Header.Get("Content-Type")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	request := &HTTPRequestV1{
+		Url:     server.URL,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    `{"serial":"{{.Serial}}","page":{{.Page}},"key":{{.Key}},"app":"{{.Application.Class}}"}`,
+	}
+	status, respBody, err := request.Do(server.Client(), HTTPTemplateData{
+		Serial:      "AL12",
+		Page:        2,
+		Key:         7,
+		Application: ApplicationContext{Class: "firefox"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusAccepted || string(respBody) != `{"ok":true}` {
+		t.Errorf("got status %d body %s", status, respBody)
+	}
+	if method != http.MethodPost {
+		t.Errorf("a request with a body was sent as %s", method)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type header was %q", contentType)
+	}
+	if want := `{"serial":"AL12","page":2,"key":7,"app":"firefox"}`; body != want {
+		t.Errorf("got body %s, want %s", body, want)
+	}
+}
+
+func TestHTTPRequestBadTemplate(t *testing.T) {
+	request := &HTTPRequestV1{Url: "http://127.0.0.1:0", Body: "{{.Missing"}
+	_, _, err := request.Do(http.DefaultClient, HTTPTemplateData{})
+	if err == nil {
+		t.Error("sent a request with a body template that doesn't parse")
+	}
+}
+
+func TestHTTPRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	request := &HTTPRequestV1{Url: server.URL, Timeout: 50}
+	started := time.Now()
+	_, _, err := request.Do(server.Client(), HTTPTemplateData{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want a deadline exceeded error", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("timed out after %s", elapsed)
+	}
+}
+
+func TestLookupJSONPath(t *testing.T) {
+	document := []byte(`{"state":"on","data":{"items":[{"name":"first"},{"name":"second","count":3}],"empty":null}}`)
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "state", want: "on"},
+		{path: "data.items.1.name", want: "second"},
+		{path: "data.items.1.count", want: "3"},
+		{path: "data.items.0", want: `{"name":"first"}`},
+		{path: "data.empty", want: ""},
+		{path: "missing", wantErr: true},
+		{path: "data.items.2", wantErr: true},
+		{path: "data.items.name", wantErr: true},
+		{path: "state.value", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := lookupJSONPath(document, test.path)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %q, want an error", test.path, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.path, err)
+		} else if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.path, got, test.want)
+		}
+	}
+
+	_, err := lookupJSONPath([]byte("not json"), "state")
+	if err == nil {
+		t.Error("looked up a path in a response that isn't JSON")
+	}
+}
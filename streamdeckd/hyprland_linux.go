@@ -0,0 +1,180 @@
+//go:build linux
+
+package streamdeckd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type HyprlandActiveWindow struct {
+	Class     string `json:"class"`
+	Title     string `json:"title"`
+	Pid       int    `json:"pid"`
+	Workspace struct {
+		Name string `json:"name"`
+	} `json:"workspace"`
+}
+
+var hyprlandWindowSem sync.Mutex
+var hyprlandWindow HyprlandActiveWindow
+
+// GetHyprlandActiveWindow returns the last active window reported by Hyprland, including the
+// window title and workspace, which aren't part of the application
+func GetHyprlandActiveWindow() HyprlandActiveWindow {
+	hyprlandWindowSem.Lock()
+	defer hyprlandWindowSem.Unlock()
+	return hyprlandWindow
+}
+
+func hyprlandSocketPath(name string) string {
+	signature := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	path := filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "hypr", signature, name)
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	// Hyprland versions before 0.40 kept their sockets in /tmp
+	return filepath.Join("/tmp", "hypr", signature, name)
+}
+
+// hyprlandRequest sends a single request over Hyprland's command socket, the same as hyprctl does
+func hyprlandRequest(request string) ([]byte, error) {
+	conn, err := net.Dial("unix", hyprlandSocketPath(".socket.sock"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(request))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(conn)
+}
+
+// getHyprlandActiveWindow asks for the full details of the active window, the activewindow
+// event only carries the class and title
+func getHyprlandActiveWindow() (*HyprlandActiveWindow, error) {
+	out, err := hyprlandRequest("j/activewindow")
+	if err != nil {
+		return nil, err
+	}
+	var activeWindow HyprlandActiveWindow
+	err = json.Unmarshal(out, &activeWindow)
+	if err != nil {
+		return nil, err
+	}
+	return &activeWindow, nil
+}
+
+func updateHyprlandWindow(contexts chan<- ApplicationContext, update func(window *HyprlandActiveWindow)) {
+	hyprlandWindowSem.Lock()
+	update(&hyprlandWindow)
+	context := ApplicationContext{
+		Class:     hyprlandWindow.Class,
+		Title:     hyprlandWindow.Title,
+		Pid:       hyprlandWindow.Pid,
+		Workspace: hyprlandWindow.Workspace.Name,
+	}
+	hyprlandWindowSem.Unlock()
+	contexts <- context
+}
+
+type HyprlandProvider struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (p *HyprlandProvider) Available() bool {
+	return desktopIs("Hyprland")
+}
+
+// Start reads the active window once, then follows the event socket until it drops
+func (p *HyprlandProvider) Start(contexts chan<- ApplicationContext) error {
+	activeWindow, err := getHyprlandActiveWindow()
+	if err != nil {
+		return err
+	}
+	updateHyprlandWindow(contexts, func(window *HyprlandActiveWindow) {
+		*window = *activeWindow
+	})
+
+	conn, err := net.Dial("unix", hyprlandSocketPath(".socket2.sock"))
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.conn = conn
+	p.mu.Unlock()
+	defer p.Stop()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		event, data, found := strings.Cut(scanner.Text(), ">>")
+		if !found {
+			continue
+		}
+		handleHyprlandEvent(contexts, event, data)
+	}
+	p.mu.Lock()
+	stopped := p.conn == nil
+	p.mu.Unlock()
+	if stopped {
+		return nil
+	}
+	if scanner.Err() != nil {
+		return scanner.Err()
+	}
+	return errors.New("hyprland closed the event socket")
+}
+
+func (p *HyprlandProvider) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+func handleHyprlandEvent(contexts chan<- ApplicationContext, event, data string) {
+	switch event {
+	case "activewindow":
+		class, title, _ := strings.Cut(data, ",")
+		activeWindow, err := getHyprlandActiveWindow()
+		updateHyprlandWindow(contexts, func(window *HyprlandActiveWindow) {
+			if err == nil && activeWindow.Class == class {
+				*window = *activeWindow
+				return
+			}
+			window.Class = class
+			window.Title = title
+			window.Pid = 0
+		})
+	case "windowtitle":
+		activeWindow, err := getHyprlandActiveWindow()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		updateHyprlandWindow(contexts, func(window *HyprlandActiveWindow) {
+			*window = *activeWindow
+		})
+	case "workspace":
+		updateHyprlandWindow(contexts, func(window *HyprlandActiveWindow) {
+			window.Workspace.Name = data
+		})
+	case "focusedmon":
+		_, workspace, _ := strings.Cut(data, ",")
+		updateHyprlandWindow(contexts, func(window *HyprlandActiveWindow) {
+			window.Workspace.Name = workspace
+		})
+	}
+}
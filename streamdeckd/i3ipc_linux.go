@@ -0,0 +1,243 @@
+//go:build linux
+
+package streamdeckd
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+// https://i3wm.org/docs/ipc.html, sway implements the same protocol
+
+const i3IPCMagic = "i3-ipc"
+
+const (
+	i3IPCSubscribe   uint32 = 2
+	i3IPCGetTree     uint32 = 4
+	i3IPCWindowEvent uint32 = 0x80000003
+)
+
+type I3Node struct {
+	Focused          bool   `json:"focused"`
+	AppId            string `json:"app_id"`
+	Name             string `json:"name"`
+	Pid              int    `json:"pid"`
+	WindowProperties struct {
+		Class string `json:"class"`
+	} `json:"window_properties"`
+	Nodes         []I3Node `json:"nodes"`
+	FloatingNodes []I3Node `json:"floating_nodes"`
+}
+
+type I3WindowEvent struct {
+	Change    string `json:"change"`
+	Container I3Node `json:"container"`
+}
+
+// Class is the Wayland app_id for native Wayland windows, and the X11 class for everything else
+func (n *I3Node) Class() string {
+	if n.AppId != "" {
+		return n.AppId
+	}
+	return n.WindowProperties.Class
+}
+
+func (n *I3Node) Context() ApplicationContext {
+	return ApplicationContext{Class: n.Class(), Title: n.Name, Pid: n.Pid}
+}
+
+func (n *I3Node) findFocused() *I3Node {
+	if n.Focused {
+		return n
+	}
+	for i := range n.Nodes {
+		if focused := n.Nodes[i].findFocused(); focused != nil {
+			return focused
+		}
+	}
+	for i := range n.FloatingNodes {
+		if focused := n.FloatingNodes[i].findFocused(); focused != nil {
+			return focused
+		}
+	}
+	return nil
+}
+
+type I3IPC struct {
+	conn net.Conn
+}
+
+func DialI3IPC(socketPath string) (*I3IPC, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	return &I3IPC{conn: conn}, nil
+}
+
+func (c *I3IPC) Close() error {
+	return c.conn.Close()
+}
+
+func (c *I3IPC) Send(messageType uint32, payload []byte) error {
+	header := make([]byte, len(i3IPCMagic)+8)
+	copy(header, i3IPCMagic)
+	binary.NativeEndian.PutUint32(header[len(i3IPCMagic):], uint32(len(payload)))
+	binary.NativeEndian.PutUint32(header[len(i3IPCMagic)+4:], messageType)
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+func (c *I3IPC) Read() (uint32, []byte, error) {
+	header := make([]byte, len(i3IPCMagic)+8)
+	_, err := io.ReadFull(c.conn, header)
+	if err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(i3IPCMagic)]) != i3IPCMagic {
+		return 0, nil, errors.New("invalid i3 IPC magic string")
+	}
+	length := binary.NativeEndian.Uint32(header[len(i3IPCMagic):])
+	messageType := binary.NativeEndian.Uint32(header[len(i3IPCMagic)+4:])
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.conn, payload)
+	if err != nil {
+		return 0, nil, err
+	}
+	return messageType, payload, nil
+}
+
+// Call sends a message and waits for its reply, skipping any events that arrive first
+func (c *I3IPC) Call(messageType uint32, payload []byte) ([]byte, error) {
+	err := c.Send(messageType, payload)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		replyType, reply, err := c.Read()
+		if err != nil {
+			return nil, err
+		}
+		if replyType == messageType {
+			return reply, nil
+		}
+	}
+}
+
+func (c *I3IPC) FocusedWindow() (*I3Node, error) {
+	reply, err := c.Call(i3IPCGetTree, nil)
+	if err != nil {
+		return nil, err
+	}
+	var tree I3Node
+	err = json.Unmarshal(reply, &tree)
+	if err != nil {
+		return nil, err
+	}
+	return tree.findFocused(), nil
+}
+
+func (c *I3IPC) SubscribeWindowEvents() error {
+	reply, err := c.Call(i3IPCSubscribe, []byte(`["window"]`))
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Success bool `json:"success"`
+	}
+	err = json.Unmarshal(reply, &result)
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		return errors.New("i3 IPC subscription was rejected")
+	}
+	return nil
+}
+
+// WatchWindowFocus calls onFocus with the focused window, and again every time focus or the
+// focused window's title changes, until the connection fails
+func (c *I3IPC) WatchWindowFocus(onFocus func(window *I3Node)) error {
+	focused, err := c.FocusedWindow()
+	if err != nil {
+		return err
+	}
+	if focused != nil {
+		onFocus(focused)
+	}
+	err = c.SubscribeWindowEvents()
+	if err != nil {
+		return err
+	}
+	for {
+		messageType, payload, err := c.Read()
+		if err != nil {
+			return err
+		}
+		if messageType != i3IPCWindowEvent {
+			continue
+		}
+		var event I3WindowEvent
+		err = json.Unmarshal(payload, &event)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if event.Change == "focus" || (event.Change == "title" && event.Container.Focused) {
+			onFocus(&event.Container)
+		}
+	}
+}
+
+type I3Provider struct {
+	mu  sync.Mutex
+	ipc *I3IPC
+}
+
+func i3SocketPath() string {
+	if socketPath := os.Getenv("SWAYSOCK"); socketPath != "" {
+		return socketPath
+	}
+	return os.Getenv("I3SOCK")
+}
+
+func (p *I3Provider) Available() bool {
+	return i3SocketPath() != ""
+}
+
+func (p *I3Provider) Start(contexts chan<- ApplicationContext) error {
+	ipc, err := DialI3IPC(i3SocketPath())
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.ipc = ipc
+	p.mu.Unlock()
+	defer p.Stop()
+
+	err = ipc.WatchWindowFocus(func(window *I3Node) {
+		contexts <- window.Context()
+	})
+	p.mu.Lock()
+	stopped := p.ipc == nil
+	p.mu.Unlock()
+	if stopped {
+		return nil
+	}
+	return err
+}
+
+func (p *I3Provider) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ipc != nil {
+		p.ipc.Close()
+		p.ipc = nil
+	}
+}
@@ -0,0 +1,119 @@
+//go:build linux
+
+package streamdeckd
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeI3 is an i3 IPC server that answers GET_TREE with tree and SUBSCRIBE with success, then
+// sends events to the subscriber
+type fakeI3 struct {
+	t        *testing.T
+	listener net.Listener
+	tree     string
+	events   []string
+}
+
+func startFakeI3(t *testing.T, tree string, events ...string) string {
+	socketPath := filepath.Join(t.TempDir(), "i3.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	server := &fakeI3{t: t, listener: listener, tree: tree, events: events}
+	go server.serve()
+	return socketPath
+}
+
+func (s *fakeI3) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	ipc := &I3IPC{conn: conn}
+	for {
+		messageType, payload, err := ipc.Read()
+		if err != nil {
+			return
+		}
+		switch messageType {
+		case i3IPCGetTree:
+			ipc.Send(i3IPCGetTree, []byte(s.tree))
+		case i3IPCSubscribe:
+			if string(payload) != `["window"]` {
+				s.t.Errorf("subscribed to %s", payload)
+			}
+			// an event arriving before the reply is skipped by Call
+			ipc.Send(i3IPCWindowEvent, []byte(`{"change":"new","container":{}}`))
+			ipc.Send(i3IPCSubscribe, []byte(`{"success":true}`))
+			for _, event := range s.events {
+				ipc.Send(i3IPCWindowEvent, []byte(event))
+			}
+		}
+	}
+}
+
+func TestI3IPCFraming(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go (&I3IPC{conn: client}).Send(i3IPCGetTree, []byte("payload"))
+
+	ipc := &I3IPC{conn: server}
+	messageType, payload, err := ipc.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messageType != i3IPCGetTree || string(payload) != "payload" {
+		t.Errorf("read type %d payload %q", messageType, payload)
+	}
+
+	go client.Write([]byte("not-i3\x00\x00\x00\x00\x00\x00\x00\x00"))
+	_, _, err = ipc.Read()
+	if err == nil {
+		t.Error("read a message with the wrong magic string")
+	}
+}
+
+func TestI3ProviderContexts(t *testing.T) {
+	tree := `{"nodes":[{"nodes":[
+		{"name":"Terminal","app_id":"foot","pid":10},
+		{"name":"Inbox - Mozilla Firefox","pid":20,"focused":true,"window_properties":{"class":"firefox"}}
+	]}]}`
+	socketPath := startFakeI3(t, tree,
+		`{"change":"focus","container":{"name":"Terminal","app_id":"foot","pid":10,"focused":true}}`,
+		`{"change":"title","container":{"name":"unfocused","app_id":"foot","pid":11}}`,
+		`{"change":"title","container":{"name":"~/src","app_id":"foot","pid":10,"focused":true}}`,
+	)
+	t.Setenv("SWAYSOCK", socketPath)
+
+	provider := &I3Provider{}
+	if !provider.Available() {
+		t.Fatal("provider is not available with SWAYSOCK set")
+	}
+	contexts := make(chan ApplicationContext)
+	go provider.Start(contexts)
+	defer provider.Stop()
+
+	want := []ApplicationContext{
+		{Class: "firefox", Title: "Inbox - Mozilla Firefox", Pid: 20},
+		{Class: "foot", Title: "Terminal", Pid: 10},
+		{Class: "foot", Title: "~/src", Pid: 10},
+	}
+	for _, expected := range want {
+		select {
+		case context := <-contexts:
+			if context != expected {
+				t.Errorf("got %+v, want %+v", context, expected)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %+v", expected)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package streamdeckd
+
+import (
+	"log"
+	"sync"
+
+	streamdeck "github.com/unix-streamdeck/driver"
+)
+
+// InputLockV1 sets how a deck whose input has been locked is unlocked
+type InputLockV1 struct {
+	// UnlockKeys are held down together to unlock the deck, defaults to its first and last keys
+	UnlockKeys []int `json:"unlock_keys,omitempty"`
+}
+
+type inputLock struct {
+	mu         sync.Mutex
+	locked     bool
+	held       map[int]bool
+	unlockKeys []int
+}
+
+// unlockKeys returns the keys that unlock a deck with keys keys, falling back to its first and
+// last keys if any of the configured ones aren't on the deck
+func (l *InputLockV1) unlockKeys(keys int, logger *log.Logger) []int {
+	if l != nil && len(l.UnlockKeys) > 0 {
+		valid := true
+		for _, key := range l.UnlockKeys {
+			if key < 0 || key >= keys {
+				valid = false
+			}
+		}
+		if valid {
+			return l.UnlockKeys
+		}
+		logger.Println("[WARN] unlock_keys", l.UnlockKeys, "has keys the deck doesn't have, it has", keys, "keys, using its first and last keys instead")
+	}
+	if keys < 2 {
+		return []int{0}
+	}
+	return []int{0, keys - 1}
+}
+
+// LoadInputLock reads the deck's unlock keys from the config, it is called whenever the config
+// changes
+func (im *InputManager) LoadInputLock() {
+	var lockConfig *InputLockV1
+	if deckExt := findDeckExt(im.vdev.Serial()); deckExt != nil {
+		lockConfig = deckExt.InputLock
+	}
+	unlockKeys := lockConfig.unlockKeys(len(im.KeyStates), im.vdev.Logger())
+	im.lock.mu.Lock()
+	defer im.lock.mu.Unlock()
+	im.lock.unlockKeys = unlockKeys
+}
+
+// SetInputLocked stops the deck responding to its keys, knobs and touch screen until it is
+// unlocked, it keeps showing its pages as normal
+func (im *InputManager) SetInputLocked(locked bool) {
+	im.lock.mu.Lock()
+	defer im.lock.mu.Unlock()
+	if im.lock.locked == locked {
+		return
+	}
+	im.lock.locked = locked
+	im.lock.held = make(map[int]bool)
+	if locked {
+		im.vdev.Logger().Println("Input locked")
+	} else {
+		im.vdev.Logger().Println("Input unlocked")
+	}
+}
+
+func (im *InputManager) InputLocked() bool {
+	im.lock.mu.Lock()
+	defer im.lock.mu.Unlock()
+	return im.lock.locked
+}
+
+// HandleLockedInput takes the deck's input while it is locked, only watching for the unlock
+// keys. Keys that were down when the deck was locked are still released
+func (im *InputManager) HandleLockedInput(event streamdeck.InputEvent) {
+	if event.EventType != streamdeck.KEY_PRESS && event.EventType != streamdeck.KEY_RELEASE {
+		return
+	}
+	keyIndex := int(event.Index)
+	if event.EventType == streamdeck.KEY_RELEASE && keyIndex < len(im.KeyStates) && im.KeyStates[keyIndex] {
+		im.releaseKey(keyIndex)
+	}
+	im.lock.mu.Lock()
+	if !im.lock.locked {
+		im.lock.mu.Unlock()
+		return
+	}
+	im.lock.held[keyIndex] = event.EventType == streamdeck.KEY_PRESS
+	unlock := true
+	for _, key := range im.lock.unlockKeys {
+		if !im.lock.held[key] {
+			unlock = false
+			break
+		}
+	}
+	im.lock.mu.Unlock()
+	if unlock {
+		im.SetInputLocked(false)
+	}
+}
+
+// releaseKey lets go of a key that was held when the deck was locked
+func (im *InputManager) releaseKey(keyIndex int) {
+	page := im.vdev.PageManager().GetPage()
+	pages := im.vdev.Config().Pages
+	if page < len(pages) && keyIndex < len(pages[page].Keys) {
+		im.HandleKeyInput(&pages[page].Keys[keyIndex], streamdeck.InputEvent{
+			EventType: streamdeck.KEY_RELEASE,
+			Index:     uint8(keyIndex),
+		})
+		return
+	}
+	im.KeyStates[keyIndex] = false
+	im.vdev.RedrawKey(keyIndex)
+}
@@ -1,6 +1,9 @@
 package streamdeckd
 
 import (
+	"context"
+	"sync"
+
 	"github.com/unix-streamdeck/api/v2"
 	streamdeck "github.com/unix-streamdeck/driver"
 )
@@ -9,11 +12,27 @@ type IInputManager interface {
 	HandleKeyInput(key *api.KeyV3, event streamdeck.InputEvent)
 	HandleKnobInput(knob *api.KnobV3, event streamdeck.InputEvent)
 	GetKeyState(index int) bool
+	RunAction(action *ActionV1)
+	RunMacro(id string, actions []ActionV1)
+	HandleSwipe(event streamdeck.InputEvent)
+	SetInputLocked(locked bool)
+	InputLocked() bool
+	HandleLockedInput(event streamdeck.InputEvent)
+	LoadInputLock()
+	AttachPageChangeListener()
 }
 
 type InputManager struct {
 	vdev      IVirtualDev
 	KeyStates []bool
+	macros    Macros
+	knobTurns knobTurns
+	confirms  confirmations
+	lock      inputLock
+	chords    chordGroup
+
+	feedbackSem  sync.Mutex
+	feedbackKeys map[int]bool
 }
 
 func (im *InputManager) HandleKeyInput(key *api.KeyV3, event streamdeck.InputEvent) {
@@ -26,22 +45,18 @@ func (im *InputManager) HandleKeyInput(key *api.KeyV3, event streamdeck.InputEve
 		im.KeyStates[event.Index] = true
 		im.vdev.RedrawKey(int(event.Index))
 
-		im.handleStandardActions(keyConfig)
-
-		im.handleHandlerAction(keyConfig, api.KEY, event)
-
+		press := func() {
+			im.pressKey(key, keyConfig, event)
+		}
+		if !im.holdForChord(int(event.Index), press) {
+			press()
+		}
 	} else {
 		im.KeyStates[event.Index] = false
 		im.vdev.RedrawKey(int(event.Index))
-	}
 
-	if keyConfig.KeyHold != 0 {
-		if event.EventType == streamdeck.KEY_PRESS {
-			err := kb.KeyDown(keyConfig.KeyHold)
-			if err != nil {
-				im.vdev.Logger().Println(err)
-			}
-		} else {
+		consumed := im.releaseFromChord(int(event.Index))
+		if keyConfig.KeyHold != 0 && !consumed {
 			err := kb.KeyUp(keyConfig.KeyHold)
 			if err != nil {
 				im.vdev.Logger().Println(err)
@@ -50,25 +65,158 @@ func (im *InputManager) HandleKeyInput(key *api.KeyV3, event streamdeck.InputEve
 	}
 }
 
+// pressKey runs a key's actions, once any chord it could be part of is ruled out
+func (im *InputManager) pressKey(key *api.KeyV3, keyConfig *api.KeyConfigV3, event streamdeck.InputEvent) {
+	keyExt := findDeckExt(im.vdev.Serial()).Page(im.vdev.PageManager().GetPage()).Key(int(event.Index), key.ActiveApplication)
+	if keyExt != nil && keyExt.Confirm != nil && !im.confirmKey(keyExt.Confirm, int(event.Index)) {
+		return
+	}
+	var commandOptions *CommandOptionsV1
+	if keyExt != nil {
+		commandOptions = keyExt.CommandOptions
+	}
+
+	im.handleStandardActions(keyConfig, commandOptions, int(event.Index))
+
+	im.handleHandlerAction(keyConfig, api.KEY, event, nil)
+
+	if keyExt != nil {
+		go im.handleExtendedActions(&keyExt.ExtendedActionsV1, int(event.Index))
+	}
+	if keyExt != nil && len(keyExt.States) > 0 {
+		go im.vdev.MultiStateKeys().Press(int(event.Index), im.vdev.PageManager().GetPage(), key.ActiveApplication)
+	}
+	if keyExt != nil && len(keyExt.Actions) > 0 {
+		im.macros.Run(im, keyMacroId(int(event.Index), im.vdev.PageManager().GetPage(), key.ActiveApplication), keyExt.Actions, keyExt.CancelOnRepress)
+	}
+	if keyExt != nil && keyExt.DynamicPage != "" {
+		im.vdev.DynamicPager().Open(keyExt.DynamicPage, keyExt.DynamicPageFields)
+	}
+
+	if keyConfig.KeyHold != 0 {
+		err := kb.KeyDown(keyConfig.KeyHold)
+		if err != nil {
+			im.vdev.Logger().Println(err)
+		}
+	}
+}
+
 func (im *InputManager) HandleKnobInput(knob *api.KnobV3, event streamdeck.InputEvent) {
 	knobConfig, ok := knob.Application[knob.ActiveApplication]
 	if !ok {
 		im.vdev.Logger().Println("Err getting correct config for knob")
 		return
 	}
-	im.handleHandlerAction(knobConfig, api.LCD, event)
+	knobExt := findDeckExt(im.vdev.Serial()).Page(im.vdev.PageManager().GetPage()).Knob(int(event.Index), knob.ActiveApplication)
+	if knobExt != nil && knobExt.Confirm != nil && event.EventType != streamdeck.KNOB_CW && event.EventType != streamdeck.KNOB_CCW {
+		if !im.confirmKnob(knobExt.Confirm, int(event.Index), knobConfirmAction(event.EventType)) {
+			return
+		}
+	}
+	im.handleHandlerAction(knobConfig, api.LCD, event, nil)
+	if event.EventType == streamdeck.SCREEN_SHORT_TAP || event.EventType == streamdeck.SCREEN_LONG_TAP {
+		im.handleTapActions(knobExt, event)
+		return
+	}
+	if knobExt.hasPressedTurn() {
+		window := knobExt.Turn.pressWindow()
+		if event.EventType == streamdeck.KNOB_PRESS {
+			im.knobTurns.holdPress(int(event.Index), window, func() {
+				im.handleKnobActions(knobConfig, knobExt, knob.ActiveApplication, event)
+			})
+			return
+		}
+		if im.knobTurns.pressedTurn(int(event.Index), window) {
+			im.handlePressedTurn(knobExt, knob.ActiveApplication, event)
+			return
+		}
+	}
+	im.handleKnobActions(knobConfig, knobExt, knob.ActiveApplication, event)
+}
+
+func (im *InputManager) handleKnobActions(knobConfig *api.KnobConfigV3, knobExt *KnobConfigExtV3, application string, event streamdeck.InputEvent) {
 	var actions api.KnobActionV3
+	var actionsExt *KnobActionExtV3
+	var actionName string
 	if event.EventType == streamdeck.KNOB_PRESS {
 		actions = knobConfig.KnobPressAction
+		actionName = "press"
+		if knobExt != nil {
+			actionsExt = knobExt.KnobPressAction
+		}
 	} else if event.EventType == streamdeck.KNOB_CCW {
 		actions = knobConfig.KnobTurnDownAction
+		actionName = "turn down"
+		if knobExt != nil {
+			actionsExt = knobExt.KnobTurnDownAction
+		}
 	} else if event.EventType == streamdeck.KNOB_CW {
 		actions = knobConfig.KnobTurnUpAction
+		actionName = "turn up"
+		if knobExt != nil {
+			actionsExt = knobExt.KnobTurnUpAction
+		}
+	}
+	runs := 1
+	if event.EventType != streamdeck.KNOB_PRESS {
+		var turn *KnobTurnV1
+		if knobExt != nil {
+			turn = knobExt.Turn
+		}
+		runs = im.knobTurns.allow(knobMacroId(int(event.Index), im.vdev.PageManager().GetPage(), application, actionName), turn.repeats(int(event.RotateNotches)), turn.rateLimit(actions.Command))
+	}
+	var commandOptions *CommandOptionsV1
+	var extendedActions *ExtendedActionsV1
+	if actionsExt != nil {
+		commandOptions = actionsExt.CommandOptions
+		extendedActions = &actionsExt.ExtendedActionsV1
+	}
+	im.repeatActions(&actions, commandOptions, extendedActions, runs)
+	if knobExt != nil && knobExt.Scroll != nil && (event.EventType == streamdeck.KNOB_CW || event.EventType == streamdeck.KNOB_CCW) {
+		notches := max(int(event.RotateNotches), 1)
+		if event.EventType == streamdeck.KNOB_CCW {
+			notches = -notches
+		}
+		err := knobExt.Scroll.Scroll(notches)
+		if err != nil {
+			im.vdev.Logger().Println("[ERROR] Failed to scroll mouse:", err)
+		}
 	}
-	im.handleStandardActions(&actions)
+	if runs > 0 && actionsExt != nil && len(actionsExt.Actions) > 0 {
+		im.macros.Run(im, knobMacroId(int(event.Index), im.vdev.PageManager().GetPage(), application, actionName), actionsExt.Actions, actionsExt.CancelOnRepress)
+	}
+}
+
+func (im *InputManager) handlePressedTurn(knobExt *KnobConfigExtV3, application string, event streamdeck.InputEvent) {
+	action := knobExt.PressedTurnUpAction
+	actionName := "pressed turn up"
+	if event.EventType == streamdeck.KNOB_CCW {
+		action = knobExt.PressedTurnDownAction
+		actionName = "pressed turn down"
+	}
+	if action == nil {
+		return
+	}
+	runs := im.knobTurns.allow(knobMacroId(int(event.Index), im.vdev.PageManager().GetPage(), application, actionName), knobExt.Turn.repeats(int(event.RotateNotches)), knobExt.Turn.rateLimit(action.Command))
+	im.repeatActions(action, action.CommandOptions, &action.ExtendedActionsV1, runs)
 }
 
-func (im *InputManager) handleHandlerAction(foregroundActions api.ForegroundAndInputHandlerConfig, handlerType api.HandlerType, event streamdeck.InputEvent) {
+func (im *InputManager) handleTapActions(knobExt *KnobConfigExtV3, event streamdeck.InputEvent) {
+	if knobExt == nil {
+		return
+	}
+	action := knobExt.ShortTapAction
+	if event.EventType == streamdeck.SCREEN_LONG_TAP {
+		action = knobExt.LongTapAction
+	}
+	if action != nil {
+		im.RunAction(action)
+	}
+}
+
+// handleHandlerAction sends event to the input handler, extraFields are added to the handler's
+// fields for this event only
+func (im *InputManager) handleHandlerAction(foregroundActions api.ForegroundAndInputHandlerConfig, handlerType api.HandlerType, event streamdeck.InputEvent, extraFields map[string]any) {
 	if foregroundActions.GetInputHandler() != "" {
 		var deckInfo api.StreamDeckInfoV1
 		deckInfo = *im.vdev.SdInfo()
@@ -96,11 +244,14 @@ func (im *InputManager) handleHandlerAction(foregroundActions api.ForegroundAndI
 				foregroundActions.SetInputHandlerInstance(comboHandler)
 			}
 		}
+		if len(extraFields) > 0 {
+			fields = mergeSharedConfig(fields, extraFields)
+		}
 		inputEvent := api.InputEvent{
 			EventType:     api.InputEventType(event.EventType),
 			RotateNotches: event.RotateNotches,
 		}
-		if event.EventType == streamdeck.SCREEN_SHORT_TAP || event.EventType == streamdeck.SCREEN_LONG_TAP {
+		if event.EventType == streamdeck.SCREEN_SHORT_TAP || event.EventType == streamdeck.SCREEN_LONG_TAP || event.EventType == streamdeck.SCREEN_SWIPE {
 			inputEvent.ScreenTapY = event.ScreenY
 			inputEvent.ScreenTapX = event.ScreenX - uint16(int(event.Index)*deckInfo.LcdWidth)
 		}
@@ -109,8 +260,12 @@ func (im *InputManager) handleHandlerAction(foregroundActions api.ForegroundAndI
 	}
 }
 
-func (im *InputManager) handleStandardActions(ia api.InputActions) {
-	if ia.GetCommand() != "" {
+// handleStandardActions runs the actions api.InputActions covers, the command is run with
+// options when they are set, showing its feedback on keyIndex, or nowhere if it is -1
+func (im *InputManager) handleStandardActions(ia api.InputActions, commandOptions *CommandOptionsV1, keyIndex int) {
+	if ia.GetCommand() != "" && commandOptions != nil {
+		go im.runCommandWithOptions(context.Background(), ia.GetCommand(), commandOptions, keyIndex)
+	} else if ia.GetCommand() != "" {
 		RunCommand(ia.GetCommand())
 	}
 	if ia.GetKeyBind() != "" {
@@ -137,6 +292,14 @@ func (im *InputManager) handleStandardActions(ia api.InputActions) {
 	}
 }
 
+func (im *InputManager) AttachPageChangeListener() {
+	im.vdev.PageManager().AttachListener(func(_, _ int) {
+		im.clearFeedback()
+		im.confirms.cancelAll()
+		im.chords.cancel()
+	})
+}
+
 func (im *InputManager) GetKeyState(index int) bool {
 	return im.KeyStates[index]
 }
@@ -0,0 +1,216 @@
+//go:build linux
+
+package streamdeckd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const i3IPCGetInputs uint32 = 100
+
+// keyboardLayoutQueryTimeout is how long the session gets to say which layout is active
+const keyboardLayoutQueryTimeout = 2 * time.Second
+
+// keyboardLayoutDescriptions are the names sway and Hyprland give the layouts, mapped back to
+// their XKB names
+var keyboardLayoutDescriptions = map[string]string{
+	"English (US)": "us",
+	"English (UK)": "gb",
+}
+
+// sessionKeyboardLayout asks the compositor, or the X server, which layout is active right now.
+// It returns "" if the session has no way of saying, and an error if it does but the layout
+// couldn't be read
+func sessionKeyboardLayout() (string, error) {
+	switch {
+	case os.Getenv("SWAYSOCK") != "":
+		return swayKeyboardLayout()
+	case desktopIs("Hyprland"):
+		return hyprlandKeyboardLayout()
+	case desktopIs("KDE"):
+		return kdeKeyboardLayout()
+	case desktopIs("GNOME"):
+		return gnomeKeyboardLayout()
+	case os.Getenv("XDG_SESSION_TYPE") == "x11":
+		return x11KeyboardLayout()
+	}
+	return "", nil
+}
+
+// layoutFromDescription turns a layout description like "English (UK)" into its XKB name
+func layoutFromDescription(description string) (string, error) {
+	name, ok := keyboardLayoutDescriptions[description]
+	if !ok {
+		return "", fmt.Errorf("keyboard layout %s is not supported for typing text, set keyboard_layout to one that is", description)
+	}
+	return name, nil
+}
+
+type swayInput struct {
+	Type                 string   `json:"type"`
+	XkbLayoutNames       []string `json:"xkb_layout_names"`
+	XkbActiveLayoutIndex int      `json:"xkb_active_layout_index"`
+}
+
+func swayKeyboardLayout() (string, error) {
+	ipc, err := DialI3IPC(os.Getenv("SWAYSOCK"))
+	if err != nil {
+		return "", err
+	}
+	defer ipc.Close()
+	reply, err := ipc.Call(i3IPCGetInputs, nil)
+	if err != nil {
+		return "", err
+	}
+	var inputs []swayInput
+	err = json.Unmarshal(reply, &inputs)
+	if err != nil {
+		return "", err
+	}
+	for _, input := range inputs {
+		if input.Type != "keyboard" || input.XkbActiveLayoutIndex >= len(input.XkbLayoutNames) {
+			continue
+		}
+		return layoutFromDescription(input.XkbLayoutNames[input.XkbActiveLayoutIndex])
+	}
+	return "", errors.New("sway has no keyboards with a layout")
+}
+
+type hyprlandDevices struct {
+	Keyboards []struct {
+		Main         bool   `json:"main"`
+		ActiveKeymap string `json:"active_keymap"`
+	} `json:"keyboards"`
+}
+
+func hyprlandKeyboardLayout() (string, error) {
+	out, err := hyprlandRequest("j/devices")
+	if err != nil {
+		return "", err
+	}
+	var devices hyprlandDevices
+	err = json.Unmarshal(out, &devices)
+	if err != nil {
+		return "", err
+	}
+	for _, keyboard := range devices.Keyboards {
+		if keyboard.Main {
+			return layoutFromDescription(keyboard.ActiveKeymap)
+		}
+	}
+	if len(devices.Keyboards) > 0 {
+		return layoutFromDescription(devices.Keyboards[0].ActiveKeymap)
+	}
+	return "", errors.New("Hyprland has no keyboards")
+}
+
+type kdeLayout struct {
+	ShortName   string
+	VariantName string
+	LongName    string
+}
+
+func kdeKeyboardLayout() (string, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), keyboardLayoutQueryTimeout)
+	defer cancel()
+	layouts := conn.Object("org.kde.keyboard", "/Layouts")
+	var index uint32
+	err = layouts.CallWithContext(ctx, "org.kde.KeyboardLayouts.getLayout", 0).Store(&index)
+	if err != nil {
+		return "", err
+	}
+	var list []kdeLayout
+	err = layouts.CallWithContext(ctx, "org.kde.KeyboardLayouts.getLayoutsList", 0).Store(&list)
+	if err != nil {
+		return "", err
+	}
+	if int(index) >= len(list) {
+		return "", fmt.Errorf("KDE's active keyboard layout %d isn't in its list of layouts", index)
+	}
+	if list[index].VariantName != "" {
+		return list[index].ShortName + "+" + list[index].VariantName, nil
+	}
+	return list[index].ShortName, nil
+}
+
+var gnomeInputSource = regexp.MustCompile(`\('xkb', '([^']+)'\)`)
+
+// gnomeKeyboardLayout reads the most recently used input source, which is the active one
+func gnomeKeyboardLayout() (string, error) {
+	for _, key := range []string{"mru-sources", "sources"} {
+		out, err := runKeyboardLayoutQuery("gsettings", "get", "org.gnome.desktop.input-sources", key)
+		if err != nil {
+			return "", err
+		}
+		if layout := parseGnomeInputSources(out); layout != "" {
+			return layout, nil
+		}
+	}
+	return "", nil
+}
+
+// parseGnomeInputSources returns the first XKB layout in a list like [('xkb', 'gb'), ('ibus', 'mozc-jp')]
+func parseGnomeInputSources(sources string) string {
+	match := gnomeInputSource.FindStringSubmatch(sources)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func x11KeyboardLayout() (string, error) {
+	out, err := runKeyboardLayoutQuery("setxkbmap", "-query")
+	if err != nil {
+		return "", err
+	}
+	return parseSetxkbmapQuery(out), nil
+}
+
+// parseSetxkbmapQuery returns the layout line of setxkbmap -query, with its variant if it has one
+func parseSetxkbmapQuery(query string) string {
+	var layout, variant string
+	for _, line := range strings.Split(query, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "layout":
+			layout = strings.TrimSpace(value)
+		case "variant":
+			variant = strings.TrimSpace(value)
+		}
+	}
+	// only the first of several layouts is active by default
+	layout, _, _ = strings.Cut(layout, ",")
+	variant, _, _ = strings.Cut(variant, ",")
+	if layout != "" && variant != "" {
+		return layout + "+" + variant
+	}
+	return layout
+}
+
+func runKeyboardLayoutQuery(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyboardLayoutQueryTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return string(out), nil
+}
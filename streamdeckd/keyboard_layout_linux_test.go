@@ -0,0 +1,42 @@
+//go:build linux
+
+package streamdeckd
+
+import "testing"
+
+func TestParseGnomeInputSources(t *testing.T) {
+	tests := map[string]string{
+		"[('xkb', 'gb'), ('xkb', 'us')]":              "gb",
+		"[('ibus', 'mozc-jp'), ('xkb', 'us+dvorak')]": "us+dvorak",
+		"@a(ss) []": "",
+	}
+	for sources, want := range tests {
+		if got := parseGnomeInputSources(sources); got != want {
+			t.Errorf("parseGnomeInputSources(%q) = %q, want %q", sources, got, want)
+		}
+	}
+}
+
+func TestParseSetxkbmapQuery(t *testing.T) {
+	tests := map[string]string{
+		"rules:      evdev\nmodel:      pc105\nlayout:     gb\n":                 "gb",
+		"rules:      evdev\nlayout:     us,de\nvariant:    ,nodeadkeys\n":        "us",
+		"rules:      evdev\nlayout:     us\nvariant:    dvorak\noptions:    x\n": "us+dvorak",
+	}
+	for query, want := range tests {
+		if got := parseSetxkbmapQuery(query); got != want {
+			t.Errorf("parseSetxkbmapQuery(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestLayoutFromDescription(t *testing.T) {
+	name, err := layoutFromDescription("English (UK)")
+	if err != nil || name != "gb" {
+		t.Errorf("got %q: %v, want gb", name, err)
+	}
+	_, err = layoutFromDescription("German")
+	if err == nil {
+		t.Error("an unsupported layout wasn't an error")
+	}
+}
@@ -0,0 +1,163 @@
+package streamdeckd
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/unix-streamdeck/api/v2"
+)
+
+const (
+	defaultPressWindow = time.Second
+	// defaultCommandRateLimit stops a quickly turned knob starting a process for every notch
+	defaultCommandRateLimit = 50 * time.Millisecond
+)
+
+// KnobTurnV1 changes how a knob's turn actions repeat for the notches turned
+type KnobTurnV1 struct {
+	// Acceleration is applied to the notches turned in one go, 1 (the default) runs the actions
+	// once per notch, higher values run them more often the faster the knob is turned
+	Acceleration float64 `json:"acceleration,omitempty"`
+	// RateLimit is the shortest time between runs of the turn actions, in ms, turns that come in
+	// quicker are dropped rather than queued and acceleration doesn't apply. Defaults to 50 for
+	// turn actions that run a command, -1 turns it off
+	RateLimit int `json:"rate_limit,omitempty"`
+	// PressWindow is how long after a press, or the last pressed turn, a turn still counts as
+	// pressed, in ms, defaults to 1000
+	PressWindow int `json:"press_window,omitempty"`
+}
+
+// accelerate returns how many times to act for notches turned in either direction
+func accelerate(notches int, acceleration float64) int {
+	if acceleration == 0 {
+		acceleration = 1
+	}
+	return int(math.Round(math.Pow(math.Abs(float64(notches)), acceleration)))
+}
+
+func (kt *KnobTurnV1) repeats(notches int) int {
+	notches = max(notches, 1)
+	if kt == nil {
+		return notches
+	}
+	return accelerate(notches, kt.Acceleration)
+}
+
+func (kt *KnobTurnV1) rateLimit(command string) time.Duration {
+	switch {
+	case kt != nil && kt.RateLimit < 0:
+		return 0
+	case kt != nil && kt.RateLimit > 0:
+		return time.Duration(kt.RateLimit) * time.Millisecond
+	case command != "":
+		return defaultCommandRateLimit
+	}
+	return 0
+}
+
+func (kt *KnobTurnV1) pressWindow() time.Duration {
+	if kt == nil || kt.PressWindow == 0 {
+		return defaultPressWindow
+	}
+	return time.Duration(kt.PressWindow) * time.Millisecond
+}
+
+func (k *KnobConfigExtV3) hasPressedTurn() bool {
+	return k != nil && (k.PressedTurnUpAction != nil || k.PressedTurnDownAction != nil)
+}
+
+// knobTurns tracks the rate limits of turn actions, and which knobs are held down for their
+// press-and-turn actions. The driver doesn't report knobs being released, so a knob counts as
+// held for the press window after its press, or its last turn while held
+type knobTurns struct {
+	mu        sync.Mutex
+	lastRun   map[string]time.Time
+	pressedAt map[int]time.Time
+	pending   map[int]*time.Timer
+}
+
+// allow returns how many times the action id can run for a turn. A rate limited action runs at
+// most once per rate limit, however many repeats the turn was worth, and turns in between are
+// dropped
+func (kt *knobTurns) allow(id string, repeats int, rateLimit time.Duration) int {
+	if rateLimit == 0 {
+		return repeats
+	}
+	kt.mu.Lock()
+	defer kt.mu.Unlock()
+	if kt.lastRun == nil {
+		kt.lastRun = make(map[string]time.Time)
+	}
+	if time.Since(kt.lastRun[id]) < rateLimit {
+		return 0
+	}
+	kt.lastRun[id] = time.Now()
+	return min(repeats, 1)
+}
+
+// holdPress holds back a knob's press actions for the press window, running them only if the
+// knob isn't turned in the meantime
+func (kt *knobTurns) holdPress(index int, window time.Duration, run func()) {
+	kt.mu.Lock()
+	defer kt.mu.Unlock()
+	if kt.pressedAt == nil {
+		kt.pressedAt = make(map[int]time.Time)
+		kt.pending = make(map[int]*time.Timer)
+	}
+	if pending, ok := kt.pending[index]; ok {
+		pending.Stop()
+	}
+	kt.pressedAt[index] = time.Now()
+	var timer *time.Timer
+	timer = time.AfterFunc(window, func() {
+		kt.mu.Lock()
+		if kt.pending[index] != timer {
+			kt.mu.Unlock()
+			return
+		}
+		delete(kt.pending, index)
+		delete(kt.pressedAt, index)
+		kt.mu.Unlock()
+		run()
+	})
+	kt.pending[index] = timer
+}
+
+// pressedTurn returns whether a turn of knob index happened while it was held, dropping the
+// knob's held back press actions if so
+func (kt *knobTurns) pressedTurn(index int, window time.Duration) bool {
+	kt.mu.Lock()
+	defer kt.mu.Unlock()
+	pressedAt, ok := kt.pressedAt[index]
+	if !ok || time.Since(pressedAt) >= window {
+		delete(kt.pressedAt, index)
+		return false
+	}
+	kt.pressedAt[index] = time.Now()
+	if pending, ok := kt.pending[index]; ok {
+		pending.Stop()
+		delete(kt.pending, index)
+	}
+	return true
+}
+
+// repeatActions runs ia and ea runs times. Only commands, keybinds and the extended actions
+// repeat, switching page, brightness, urls and OBS commands run once
+func (im *InputManager) repeatActions(ia api.InputActions, commandOptions *CommandOptionsV1, ea *ExtendedActionsV1, runs int) {
+	if runs < 1 {
+		return
+	}
+	im.handleStandardActions(ia, commandOptions, -1)
+	repeat := &ActionV1{Command: ia.GetCommand(), Keybind: ia.GetKeyBind()}
+	for i := 1; i < runs; i++ {
+		im.handleStandardActions(repeat, commandOptions, -1)
+	}
+	if ea != nil {
+		go func() {
+			for i := 0; i < runs; i++ {
+				im.handleExtendedActions(ea, -1)
+			}
+		}()
+	}
+}
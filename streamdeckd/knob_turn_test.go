@@ -0,0 +1,47 @@
+package streamdeckd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKnobTurnRepeats(t *testing.T) {
+	tests := []struct {
+		turn    *KnobTurnV1
+		notches int
+		want    int
+	}{
+		{nil, 3, 3},
+		{nil, 0, 1},
+		{&KnobTurnV1{}, 4, 4},
+		{&KnobTurnV1{Acceleration: 2}, 3, 9},
+		{&KnobTurnV1{Acceleration: 1.5}, 1, 1},
+	}
+	for _, test := range tests {
+		if got := test.turn.repeats(test.notches); got != test.want {
+			t.Errorf("repeats(%d) with %+v = %d, want %d", test.notches, test.turn, got, test.want)
+		}
+	}
+}
+
+func TestKnobTurnAllow(t *testing.T) {
+	var turns knobTurns
+	if runs := turns.allow("keybind", 9, 0); runs != 9 {
+		t.Errorf("action without a rate limit ran %d times, want 9", runs)
+	}
+
+	rateLimit := 50 * time.Millisecond
+	if runs := turns.allow("command", 9, rateLimit); runs != 1 {
+		t.Errorf("rate limited action ran %d times, want once", runs)
+	}
+	if runs := turns.allow("command", 9, rateLimit); runs != 0 {
+		t.Errorf("turn inside the rate limit ran %d times, want it dropped", runs)
+	}
+	if runs := turns.allow("other", 2, rateLimit); runs != 1 {
+		t.Errorf("another action was held up by the rate limit, ran %d times", runs)
+	}
+	time.Sleep(rateLimit)
+	if runs := turns.allow("command", 9, rateLimit); runs != 1 {
+		t.Errorf("turn after the rate limit ran %d times, want once", runs)
+	}
+}
@@ -0,0 +1,88 @@
+package streamdeckd
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+type Macros struct {
+	mu      sync.Mutex
+	running map[string]*runningMacro
+}
+
+type runningMacro struct {
+	cancel context.CancelFunc
+}
+
+// Run starts actions on their own goroutine. id identifies the key or knob action the macro
+// belongs to, if cancelOnRepress is set, running it while it is still going cancels it instead
+func (m *Macros) Run(im *InputManager, id string, actions []ActionV1, cancelOnRepress bool) {
+	m.mu.Lock()
+	if m.running == nil {
+		m.running = make(map[string]*runningMacro)
+	}
+	if running, ok := m.running[id]; ok && cancelOnRepress {
+		delete(m.running, id)
+		m.mu.Unlock()
+		running.cancel()
+		im.vdev.Logger().Println("Cancelled macro", id)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	macro := &runningMacro{cancel: cancel}
+	m.running[id] = macro
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			cancel()
+			m.mu.Lock()
+			if m.running[id] == macro {
+				delete(m.running, id)
+			}
+			m.mu.Unlock()
+		}()
+		for i := range actions {
+			if ctx.Err() != nil {
+				return
+			}
+			im.runMacroStep(ctx, &actions[i])
+		}
+	}()
+}
+
+func (im *InputManager) runMacroStep(ctx context.Context, action *ActionV1) {
+	if action.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(action.Delay) * time.Millisecond):
+		}
+	}
+	if action.Wait && action.Command != "" && action.CommandOptions != nil {
+		im.runCommandWithOptions(ctx, action.Command, action.CommandOptions, -1)
+		return
+	}
+	if action.Wait && action.Command != "" {
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", action.Command)
+		killGroupOnCancel(cmd)
+		err := cmd.Run()
+		if err != nil && ctx.Err() == nil {
+			im.vdev.Logger().Println("Macro command", action.Command, "failed:", err)
+		}
+		return
+	}
+	im.handleStandardActions(action, action.CommandOptions, -1)
+	im.handleExtendedActions(&action.ExtendedActionsV1, -1)
+}
+
+func keyMacroId(index int, page int, application string) string {
+	return fmt.Sprintf("key %d/%d/%s", page, index, application)
+}
+
+func knobMacroId(index int, page int, application string, action string) string {
+	return fmt.Sprintf("knob %d/%d/%s %s", page, index, application, action)
+}
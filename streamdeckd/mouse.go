@@ -0,0 +1,97 @@
+package streamdeckd
+
+import (
+	"errors"
+	"fmt"
+)
+
+type MouseMoveV1 struct {
+	X int32 `json:"x,omitempty"`
+	Y int32 `json:"y,omitempty"`
+}
+
+type MouseScrollV1 struct {
+	// Vertical scrolls up when positive, down when negative
+	Vertical int32 `json:"vertical,omitempty"`
+	// Horizontal scrolls right when positive, left when negative
+	Horizontal int32 `json:"horizontal,omitempty"`
+}
+
+// KnobScrollV1 turns a knob into a scroll wheel, turning clockwise scrolls down, or right
+type KnobScrollV1 struct {
+	Horizontal bool `json:"horizontal,omitempty"`
+	Invert     bool `json:"invert,omitempty"`
+	// Step is how far a single notch scrolls, defaults to 1
+	Step int32 `json:"step,omitempty"`
+	// Acceleration is applied to the notches turned in one go, 1 (the default) is linear,
+	// higher values scroll further the faster the knob is turned
+	Acceleration float64 `json:"acceleration,omitempty"`
+}
+
+func MouseClick(button string) error {
+	if mouse == nil {
+		return errors.New("virtual mouse is unavailable")
+	}
+	switch button {
+	case "left":
+		return mouse.LeftClick()
+	case "right":
+		return mouse.RightClick()
+	case "middle":
+		return mouse.MiddleClick()
+	default:
+		return fmt.Errorf("unknown mouse button %s", button)
+	}
+}
+
+func MouseMove(move *MouseMoveV1) error {
+	if mouse == nil {
+		return errors.New("virtual mouse is unavailable")
+	}
+	return mouse.Move(move.X, move.Y)
+}
+
+func MouseScroll(scroll *MouseScrollV1) error {
+	if mouse == nil {
+		return errors.New("virtual mouse is unavailable")
+	}
+	if scroll.Vertical != 0 {
+		if err := mouse.Wheel(false, scroll.Vertical); err != nil {
+			return err
+		}
+	}
+	if scroll.Horizontal != 0 {
+		return mouse.Wheel(true, scroll.Horizontal)
+	}
+	return nil
+}
+
+// Delta returns how far to scroll for notches turned clockwise, or anticlockwise if negative
+func (ks *KnobScrollV1) Delta(notches int) int32 {
+	step := ks.Step
+	if step == 0 {
+		step = 1
+	}
+	magnitude := int32(accelerate(notches, ks.Acceleration)) * step
+	// clockwise is down, which is negative for the vertical wheel, and right, which is
+	// positive for the horizontal one
+	positive := notches > 0
+	if !ks.Horizontal {
+		positive = !positive
+	}
+	if ks.Invert {
+		positive = !positive
+	}
+	if positive {
+		return magnitude
+	}
+	return -magnitude
+}
+
+func (ks *KnobScrollV1) Scroll(notches int) error {
+	delta := ks.Delta(notches)
+	if ks.Horizontal {
+		return MouseScroll(&MouseScrollV1{Horizontal: delta})
+	}
+	return MouseScroll(&MouseScrollV1{Vertical: delta})
+}
@@ -0,0 +1,323 @@
+package streamdeckd
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unix-streamdeck/api/v2"
+	"github.com/unix-streamdeck/streamdeckd/streamdeckd/mqtt"
+)
+
+const (
+	defaultMqttKeepAlive = 60
+	mqttMaxBackoff       = 30 * time.Second
+)
+
+// MqttConnectionInfoV1 is the broker streamdeckd connects to, for every deck
+type MqttConnectionInfoV1 struct {
+	Host string `json:"host,omitempty"`
+	// Port defaults to 1883, or 8883 with Tls
+	Port     int    `json:"port,omitempty"`
+	Tls      bool   `json:"tls,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// ClientId defaults to streamdeckd-<hostname>
+	ClientId string `json:"client_id,omitempty"`
+	// KeepAlive is in seconds, defaults to 60
+	KeepAlive int `json:"keep_alive,omitempty"`
+}
+
+// MqttPublishV1 publishes a message when its key is pressed or knob turned
+type MqttPublishV1 struct {
+	Topic   string `json:"topic"`
+	Payload string `json:"payload,omitempty"`
+	// Qos is 0 (the default) or 1
+	Qos    byte `json:"qos,omitempty"`
+	Retain bool `json:"retain,omitempty"`
+}
+
+// MqttSubscriptionV1 changes how a key looks from the messages on a topic
+type MqttSubscriptionV1 struct {
+	Topic string `json:"topic"`
+	// JSONPath picks the value out of JSON messages, as in HTTPRequestV1
+	JSONPath string `json:"json_path,omitempty"`
+	// Values sets how the key looks for each value, "" matches any value without its own entry.
+	// {value} in their text is replaced with the value. Without a matching entry the value is
+	// shown as the key's text
+	Values map[string]*KeyLookV1 `json:"values,omitempty"`
+}
+
+// look returns how a key with this subscription looks for value
+func (s *MqttSubscriptionV1) look(value string, keyConfig *api.KeyConfigV3) *KeyLookV1 {
+	look, ok := s.Values[value]
+	if !ok {
+		look, ok = s.Values[""]
+	}
+	if !ok {
+		return &KeyLookV1{
+			Icon:          keyConfig.Icon,
+			Text:          value,
+			TextSize:      keyConfig.TextSize,
+			TextAlignment: keyConfig.TextAlignment,
+			FontFace:      keyConfig.FontFace,
+			TextColour:    keyConfig.TextColour,
+		}
+	}
+	withValue := *look
+	withValue.Text = strings.ReplaceAll(look.Text, "{value}", value)
+	return &withValue
+}
+
+type mqttManager struct {
+	mu     sync.Mutex
+	info   MqttConnectionInfoV1
+	client *mqtt.Client
+	stop   chan struct{}
+	topics map[string]bool
+	// values holds the last value received for each subscribed topic filter
+	values map[string]string
+}
+
+var mqttConnection = &mqttManager{}
+
+func (info *MqttConnectionInfoV1) address() string {
+	port := info.Port
+	if port == 0 {
+		port = 1883
+		if info.Tls {
+			port = 8883
+		}
+	}
+	return net.JoinHostPort(info.Host, strconv.Itoa(port))
+}
+
+func (info *MqttConnectionInfoV1) options(onMessage func(mqtt.Message)) mqtt.Options {
+	clientId := info.ClientId
+	if clientId == "" {
+		hostname, _ := os.Hostname()
+		clientId = "streamdeckd-" + hostname
+	}
+	keepAlive := info.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = defaultMqttKeepAlive
+	}
+	options := mqtt.Options{
+		ClientId:  clientId,
+		Username:  info.Username,
+		Password:  info.Password,
+		KeepAlive: time.Duration(keepAlive) * time.Second,
+		OnMessage: onMessage,
+	}
+	if info.Tls {
+		options.TLS = &tls.Config{ServerName: info.Host}
+	}
+	return options
+}
+
+// mqttTopics returns every topic keys are subscribed to, across every deck
+func mqttTopics() map[string]bool {
+	topics := make(map[string]bool)
+	if configExt == nil {
+		return topics
+	}
+	for _, deck := range configExt.Decks {
+		for _, page := range deck.Pages {
+			for _, key := range page.Keys {
+				for _, keyExt := range key.Application {
+					if keyExt != nil && keyExt.MqttSubscribe != nil && keyExt.MqttSubscribe.Topic != "" {
+						topics[keyExt.MqttSubscribe.Topic] = true
+					}
+				}
+			}
+		}
+	}
+	return topics
+}
+
+// Reconfigure connects to the broker in the config, reconnecting if it has changed, and
+// subscribes to any topics keys have been given since
+func (m *mqttManager) Reconfigure() {
+	var info MqttConnectionInfoV1
+	if configExt != nil && configExt.MqttConnectionInfo != nil {
+		info = *configExt.MqttConnectionInfo
+	}
+	topics := mqttTopics()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if info == m.info && m.stop != nil {
+		var added []string
+		for topic := range topics {
+			if !m.topics[topic] {
+				added = append(added, topic)
+			}
+		}
+		m.topics = topics
+		if m.client != nil && len(added) > 0 {
+			go m.subscribe(m.client, added)
+		}
+		return
+	}
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	m.info = info
+	m.topics = topics
+	m.values = make(map[string]string)
+	if info.Host == "" {
+		return
+	}
+	if info.Password != "" && info.Username == "" {
+		log.Println("[ERROR] MQTT connection info has a password but no username, not connecting to", info.address())
+		return
+	}
+	m.stop = make(chan struct{})
+	go m.run(info, m.stop)
+}
+
+// run keeps a connection to the broker open until stop is closed
+func (m *mqttManager) run(info MqttConnectionInfoV1, stop chan struct{}) {
+	backoff := time.Second
+	for {
+		client, err := mqtt.Dial(info.address(), info.options(m.handleMessage))
+		if err == nil {
+			log.Println("Connected to MQTT broker", info.address())
+			backoff = time.Second
+			m.mu.Lock()
+			m.client = client
+			var topics []string
+			for topic := range m.topics {
+				topics = append(topics, topic)
+			}
+			m.mu.Unlock()
+			m.subscribe(client, topics)
+			select {
+			case <-stop:
+				client.Close()
+			case <-client.Done():
+			}
+			m.mu.Lock()
+			m.client = nil
+			m.mu.Unlock()
+			err = client.Err()
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		log.Println("MQTT connection to", info.address(), "failed, retrying in", backoff, ":", err)
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, mqttMaxBackoff)
+	}
+}
+
+func (m *mqttManager) subscribe(client *mqtt.Client, topics []string) {
+	if len(topics) == 0 {
+		return
+	}
+	filters := make(map[string]byte, len(topics))
+	for _, topic := range topics {
+		filters[topic] = 0
+	}
+	err := client.Subscribe(filters)
+	if err != nil {
+		log.Println("Could not subscribe to MQTT topics:", err)
+	}
+}
+
+// handleMessage is called on the client's read loop, so redraws happen on their own goroutine
+func (m *mqttManager) handleMessage(message mqtt.Message) {
+	m.mu.Lock()
+	var matched []string
+	for topic := range m.topics {
+		if mqtt.MatchTopic(topic, message.Topic) {
+			m.values[topic] = string(message.Payload)
+			matched = append(matched, topic)
+		}
+	}
+	m.mu.Unlock()
+	if len(matched) > 0 {
+		go redrawMqttKeys(matched)
+	}
+}
+
+// Value returns the last message received on a subscribed topic
+func (m *mqttManager) Value(topic string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[topic]
+	return value, ok
+}
+
+func (m *mqttManager) Publish(publish *MqttPublishV1) error {
+	m.mu.Lock()
+	client := m.client
+	m.mu.Unlock()
+	if client == nil {
+		return errors.New("not connected to an MQTT broker")
+	}
+	return client.Publish(publish.Topic, []byte(publish.Payload), publish.Qos, publish.Retain)
+}
+
+// mqttKeyLook returns how a key subscribed to a topic looks, or nil if it isn't subscribed, or
+// nothing has been received on its topic yet
+func mqttKeyLook(serial string, keyIndex int, page int, application string, keyConfig *api.KeyConfigV3) *KeyLookV1 {
+	keyExt := findDeckExt(serial).Page(page).Key(keyIndex, application)
+	if keyExt == nil || keyExt.MqttSubscribe == nil {
+		return nil
+	}
+	value, ok := mqttConnection.Value(keyExt.MqttSubscribe.Topic)
+	if !ok {
+		return nil
+	}
+	if keyExt.MqttSubscribe.JSONPath != "" {
+		var err error
+		value, err = lookupJSONPath([]byte(value), keyExt.MqttSubscribe.JSONPath)
+		if err != nil {
+			log.Println(fmt.Sprintf("Could not read MQTT message on %s: %s", keyExt.MqttSubscribe.Topic, err))
+			return nil
+		}
+	}
+	return keyExt.MqttSubscribe.look(strings.TrimSpace(value), keyConfig)
+}
+
+// redrawMqttKeys redraws the keys showing on any deck that are subscribed to one of topics
+func redrawMqttKeys(topics []string) {
+	for _, dev := range Devs {
+		page := dev.PageManager().GetPage()
+		pages := dev.Config().Pages
+		if page >= len(pages) {
+			continue
+		}
+		deckExt := findDeckExt(dev.Serial())
+		for i := range pages[page].Keys {
+			key := &pages[page].Keys[i]
+			keyExt := deckExt.Page(page).Key(i, key.ActiveApplication)
+			if keyExt == nil || keyExt.MqttSubscribe == nil {
+				continue
+			}
+			for _, topic := range topics {
+				if keyExt.MqttSubscribe.Topic == topic {
+					if keyConfig, ok := key.Application[key.ActiveApplication]; ok {
+						dev.Foregrounder().SetKey(keyConfig, i, page, key.ActiveApplication)
+					}
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,462 @@
+// Package mqtt is a minimal MQTT 3.1.1 client, covering what streamdeckd needs: publishing,
+// subscribing at QoS 0 and 1, and keeping the connection alive
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	packetConnect     = 1
+	packetConnack     = 2
+	packetPublish     = 3
+	packetPuback      = 4
+	packetSubscribe   = 8
+	packetSuback      = 9
+	packetPingreq     = 12
+	packetPingresp    = 13
+	packetDisconnect  = 14
+	protocolLevel     = 4
+	dialTimeout       = 10 * time.Second
+	acknowledgeWait   = 10 * time.Second
+	maxRemainingBytes = 268435455
+	// maxIncomingBytes is the largest packet taken from the broker, well above anything a key
+	// shows, but without letting a broker have a quarter of a gigabyte allocated
+	maxIncomingBytes = 1024 * 1024
+)
+
+var ErrClosed = errors.New("mqtt connection closed")
+
+var ErrPacketTooLarge = errors.New("mqtt packet from the broker is too large")
+
+var ErrPasswordWithoutUsername = errors.New("mqtt password given without a username")
+
+type Message struct {
+	Topic    string
+	Payload  []byte
+	Retained bool
+}
+
+type Options struct {
+	ClientId string
+	Username string
+	Password string
+	// KeepAlive is how often the connection is checked, 0 turns the check off
+	KeepAlive time.Duration
+	// TLS connects over TLS when set
+	TLS *tls.Config
+	// OnMessage is called with the messages of subscribed topics, one at a time
+	OnMessage func(Message)
+}
+
+type Client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	opts    Options
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextId  uint16
+	pending map[uint16]chan []byte
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// Dial connects to the broker at address, returning once the broker has accepted the connection
+func Dial(address string, opts Options) (*Client, error) {
+	if opts.Password != "" && opts.Username == "" {
+		// MQTT 3.1.1 only allows a password alongside a username
+		return nil, ErrPasswordWithoutUsername
+	}
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if opts.TLS != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, opts.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newClient(conn, opts)
+}
+
+// newClient connects to the broker over conn, which is closed if the broker doesn't accept
+// the connection
+func newClient(conn net.Conn, opts Options) (*Client, error) {
+	c := &Client{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		opts:    opts,
+		pending: make(map[uint16]chan []byte),
+		done:    make(chan struct{}),
+	}
+	err := c.connect()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go c.readLoop()
+	if opts.KeepAlive > 0 {
+		go c.keepAlive()
+	}
+	return c, nil
+}
+
+func (c *Client) connect() error {
+	err := c.conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err != nil {
+		return err
+	}
+	var flags byte = 0x02 // clean session
+	if c.opts.Username != "" {
+		flags |= 0x80
+	}
+	if c.opts.Password != "" {
+		flags |= 0x40
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(c.opts.KeepAlive/time.Second))
+	body = appendString(body, c.opts.ClientId)
+	if c.opts.Username != "" {
+		body = appendString(body, c.opts.Username)
+	}
+	if c.opts.Password != "" {
+		body = appendString(body, c.opts.Password)
+	}
+	err = c.writePacket(packetConnect<<4, body)
+	if err != nil {
+		return err
+	}
+	header, ack, err := readPacket(c.reader)
+	if err != nil {
+		return err
+	}
+	if header>>4 != packetConnack || len(ack) != 2 {
+		return errors.New("broker did not acknowledge the connection")
+	}
+	if ack[1] != 0 {
+		return connectError(ack[1])
+	}
+	return c.conn.SetDeadline(time.Time{})
+}
+
+func connectError(code byte) error {
+	switch code {
+	case 1:
+		return errors.New("broker does not support MQTT 3.1.1")
+	case 2:
+		return errors.New("broker rejected the client id")
+	case 3:
+		return errors.New("broker is unavailable")
+	case 4:
+		return errors.New("bad username or password")
+	case 5:
+		return errors.New("not authorised")
+	default:
+		return fmt.Errorf("broker refused the connection with code %d", code)
+	}
+}
+
+// Publish sends payload to topic, at QoS 1 it waits for the broker to acknowledge the message
+func (c *Client) Publish(topic string, payload []byte, qos byte, retain bool) error {
+	if qos > 1 {
+		return errors.New("only QoS 0 and 1 are supported")
+	}
+	header := byte(packetPublish<<4) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	body := appendString(nil, topic)
+	var id uint16
+	var ack chan []byte
+	if qos == 1 {
+		id, ack = c.expectAck()
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	body = append(body, payload...)
+	err := c.writePacket(header, body)
+	if ack == nil {
+		return err
+	}
+	_, err = c.waitAck(id, ack, err)
+	return err
+}
+
+// Subscribe subscribes to topic filters, each with the QoS to receive its messages at
+func (c *Client) Subscribe(filters map[string]byte) error {
+	if len(filters) == 0 {
+		return nil
+	}
+	id, ack := c.expectAck()
+	body := binary.BigEndian.AppendUint16(nil, id)
+	var topics []string
+	for filter, qos := range filters {
+		body = appendString(body, filter)
+		body = append(body, min(qos, 1))
+		topics = append(topics, filter)
+	}
+	err := c.writePacket(packetSubscribe<<4|0x02, body)
+	codes, err := c.waitAck(id, ack, err)
+	if err != nil {
+		return err
+	}
+	for _, code := range codes {
+		if code == 0x80 {
+			return fmt.Errorf("broker refused a subscription to one of %s", strings.Join(topics, ", "))
+		}
+	}
+	return nil
+}
+
+// Close disconnects from the broker
+func (c *Client) Close() error {
+	err := c.writePacket(packetDisconnect<<4, nil)
+	c.fail(ErrClosed)
+	return err
+}
+
+// Done is closed when the connection is lost or closed
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection was lost, once Done is closed
+func (c *Client) Err() error {
+	<-c.done
+	return c.err
+}
+
+func (c *Client) fail(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *Client) expectAck() (uint16, chan []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		c.nextId++
+		if _, used := c.pending[c.nextId]; c.nextId != 0 && !used {
+			break
+		}
+	}
+	ack := make(chan []byte, 1)
+	c.pending[c.nextId] = ack
+	return c.nextId, ack
+}
+
+// waitAck waits for the acknowledgement of packet id, writeErr is the error from sending it
+func (c *Client) waitAck(id uint16, ack chan []byte, writeErr error) ([]byte, error) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+	if writeErr != nil {
+		return nil, writeErr
+	}
+	select {
+	case body := <-ack:
+		return body, nil
+	case <-c.done:
+		return nil, c.err
+	case <-time.After(acknowledgeWait):
+		return nil, errors.New("broker did not acknowledge in time")
+	}
+}
+
+func (c *Client) acknowledged(body []byte) {
+	if len(body) < 2 {
+		return
+	}
+	id := binary.BigEndian.Uint16(body)
+	c.mu.Lock()
+	ack, ok := c.pending[id]
+	delete(c.pending, id)
+	c.mu.Unlock()
+	if ok {
+		ack <- body[2:]
+	}
+}
+
+func (c *Client) readLoop() {
+	for {
+		if c.opts.KeepAlive > 0 {
+			// the broker answers every ping, so silence for longer than this is a dead connection
+			err := c.conn.SetReadDeadline(time.Now().Add(c.opts.KeepAlive * 3 / 2))
+			if err != nil {
+				c.fail(err)
+				return
+			}
+		}
+		header, body, err := readPacket(c.reader)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		switch header >> 4 {
+		case packetPublish:
+			err = c.handlePublish(header, body)
+		case packetPuback, packetSuback:
+			c.acknowledged(body)
+		case packetPingresp:
+		default:
+			err = fmt.Errorf("unexpected packet type %d", header>>4)
+		}
+		if err != nil {
+			c.fail(err)
+			return
+		}
+	}
+}
+
+func (c *Client) handlePublish(header byte, body []byte) error {
+	topic, rest, err := readString(body)
+	if err != nil {
+		return err
+	}
+	qos := (header >> 1) & 0x03
+	if qos > 0 {
+		if len(rest) < 2 {
+			return errors.New("publish is missing its packet id")
+		}
+		id := rest[:2]
+		rest = rest[2:]
+		if qos == 1 {
+			err = c.writePacket(packetPuback<<4, id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if c.opts.OnMessage != nil {
+		c.opts.OnMessage(Message{Topic: topic, Payload: rest, Retained: header&0x01 != 0})
+	}
+	return nil
+}
+
+func (c *Client) keepAlive() {
+	ticker := time.NewTicker(c.opts.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			err := c.writePacket(packetPingreq<<4, nil)
+			if err != nil {
+				c.fail(err)
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) writePacket(header byte, body []byte) error {
+	if len(body) > maxRemainingBytes {
+		return errors.New("packet is too large")
+	}
+	packet := []byte{header}
+	packet = appendLength(packet, len(body))
+	packet = append(packet, body...)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(packet)
+	return err
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length := 0
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if length > maxIncomingBytes {
+		return 0, nil, ErrPacketTooLarge
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+func appendLength(b []byte, length int) []byte {
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if length == 0 {
+			return b
+		}
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("malformed string")
+	}
+	length := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+length {
+		return "", nil, errors.New("malformed string")
+	}
+	return string(b[2 : 2+length]), b[2+length:], nil
+}
+
+// MatchTopic reports whether topic matches filter, with + matching a single level and # the
+// rest of the topic. Topics starting with $ are only matched by filters that name them
+func MatchTopic(filter string, topic string) bool {
+	if strings.HasPrefix(topic, "$") && !strings.HasPrefix(filter, "$") {
+		return false
+	}
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
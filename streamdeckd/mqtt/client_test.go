@@ -0,0 +1,408 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// broker is a stand-in MQTT broker for one client connection. handle is given each packet the
+// client sends after CONNECT, and answers it through the broker's conn
+type broker struct {
+	t        *testing.T
+	listener net.Listener
+	connect  chan []byte
+	// connackCode is the return code sent in the CONNACK
+	connackCode byte
+	handle      func(b *broker, conn net.Conn, header byte, body []byte)
+}
+
+func startBroker(t *testing.T, handle func(b *broker, conn net.Conn, header byte, body []byte)) *broker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	b := &broker{t: t, listener: listener, connect: make(chan []byte, 1), handle: handle}
+	go b.serve()
+	return b
+}
+
+func (b *broker) address() string {
+	return b.listener.Addr().String()
+}
+
+func (b *broker) serve() {
+	conn, err := b.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	header, body, err := readPacket(reader)
+	if err != nil || header>>4 != packetConnect {
+		b.t.Errorf("broker expected CONNECT, got header %#x: %v", header, err)
+		return
+	}
+	b.connect <- body
+	b.send(conn, packetConnack<<4, []byte{0, b.connackCode})
+	for {
+		header, body, err := readPacket(reader)
+		if err != nil || header>>4 == packetDisconnect {
+			return
+		}
+		if b.handle != nil {
+			b.handle(b, conn, header, body)
+		}
+	}
+}
+
+func (b *broker) send(conn net.Conn, header byte, body []byte) {
+	packet := appendLength([]byte{header}, len(body))
+	_, err := conn.Write(append(packet, body...))
+	if err != nil {
+		b.t.Error(err)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	b := startBroker(t, nil)
+	client, err := Dial(b.address(), Options{ClientId: "deck", Username: "user", Password: "secret", KeepAlive: 30 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	body := <-b.connect
+	name, rest, err := readString(body)
+	if err != nil || name != "MQTT" {
+		t.Fatalf("protocol name %q: %v", name, err)
+	}
+	if rest[0] != protocolLevel {
+		t.Errorf("protocol level %d", rest[0])
+	}
+	if flags := rest[1]; flags != 0x80|0x40|0x02 {
+		t.Errorf("connect flags %#x", flags)
+	}
+	if keepAlive := binary.BigEndian.Uint16(rest[2:]); keepAlive != 30 {
+		t.Errorf("keep alive %d", keepAlive)
+	}
+	rest = rest[4:]
+	for _, want := range []string{"deck", "user", "secret"} {
+		var got string
+		got, rest, err = readString(rest)
+		if err != nil || got != want {
+			t.Errorf("got %q, want %q: %v", got, want, err)
+		}
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	b := startBroker(t, nil)
+	b.connackCode = 4
+	_, err := Dial(b.address(), Options{ClientId: "deck"})
+	if err == nil || err.Error() != "bad username or password" {
+		t.Errorf("got %v, want the broker's refusal", err)
+	}
+}
+
+func TestPasswordWithoutUsername(t *testing.T) {
+	_, err := Dial("127.0.0.1:0", Options{ClientId: "deck", Password: "secret"})
+	if !errors.Is(err, ErrPasswordWithoutUsername) {
+		t.Errorf("got %v, want %v", err, ErrPasswordWithoutUsername)
+	}
+}
+
+func TestPublishQoS1(t *testing.T) {
+	published := make(chan []byte, 1)
+	b := startBroker(t, func(b *broker, conn net.Conn, header byte, body []byte) {
+		if header>>4 != packetPublish {
+			return
+		}
+		published <- append([]byte{header}, body...)
+		topic, rest, _ := readString(body)
+		if topic == "decks/deck" {
+			b.send(conn, packetPuback<<4, rest[:2])
+		}
+	})
+	client, err := Dial(b.address(), Options{ClientId: "deck"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	err = client.Publish("decks/deck", []byte("pressed"), 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := <-published
+	if qos, retain := (packet[0]>>1)&0x03, packet[0]&0x01; qos != 1 || retain != 1 {
+		t.Errorf("published with qos %d retain %d", qos, retain)
+	}
+	topic, rest, _ := readString(packet[1:])
+	if topic != "decks/deck" || string(rest[2:]) != "pressed" {
+		t.Errorf("published %q to %s", rest[2:], topic)
+	}
+
+	err = client.Publish("decks/other", []byte("pressed"), 2, false)
+	if err == nil {
+		t.Error("published at QoS 2")
+	}
+}
+
+func TestSubscribeRefused(t *testing.T) {
+	b := startBroker(t, func(b *broker, conn net.Conn, header byte, body []byte) {
+		if header != packetSubscribe<<4|0x02 {
+			b.t.Errorf("subscribe header %#x", header)
+			return
+		}
+		filter, _, _ := readString(body[2:])
+		code := byte(0)
+		if filter == "forbidden/#" {
+			code = 0x80
+		}
+		b.send(conn, packetSuback<<4, append(body[:2:2], code))
+	})
+	client, err := Dial(b.address(), Options{ClientId: "deck"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	err = client.Subscribe(map[string]byte{"allowed/+": 1})
+	if err != nil {
+		t.Errorf("subscription was refused: %v", err)
+	}
+	err = client.Subscribe(map[string]byte{"forbidden/#": 1})
+	if err == nil {
+		t.Error("broker's refusal of a subscription wasn't returned")
+	}
+}
+
+func TestReceivePublish(t *testing.T) {
+	acked := make(chan []byte, 1)
+	b := startBroker(t, func(b *broker, conn net.Conn, header byte, body []byte) {
+		switch header >> 4 {
+		case packetSubscribe:
+			b.send(conn, packetSuback<<4, append(body[:2:2], 1))
+			publish := appendString(nil, "lights/desk")
+			publish = append(publish, 0x12, 0x34)
+			b.send(conn, packetPublish<<4|0x02|0x01, append(publish, "on"...))
+		case packetPuback:
+			acked <- body
+		}
+	})
+	messages := make(chan Message, 1)
+	client, err := Dial(b.address(), Options{ClientId: "deck", OnMessage: func(message Message) {
+		messages <- message
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	err = client.Subscribe(map[string]byte{"lights/+": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := <-messages
+	if message.Topic != "lights/desk" || string(message.Payload) != "on" || !message.Retained {
+		t.Errorf("got message %+v", message)
+	}
+	if id := <-acked; !bytes.Equal(id, []byte{0x12, 0x34}) {
+		t.Errorf("acknowledged packet id %x", id)
+	}
+}
+
+func TestKeepAliveTimeout(t *testing.T) {
+	pings := make(chan struct{}, 10)
+	b := startBroker(t, func(b *broker, conn net.Conn, header byte, body []byte) {
+		if header>>4 == packetPingreq {
+			// never answered, so the client gives up on the connection
+			pings <- struct{}{}
+		}
+	})
+	client, err := Dial(b.address(), Options{ClientId: "deck", KeepAlive: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	select {
+	case <-client.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection wasn't dropped when the broker stopped answering pings")
+	}
+	var netErr net.Error
+	if err := client.Err(); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("got %v, want a timeout", err)
+	}
+	if len(pings) == 0 {
+		t.Error("client didn't ping the broker")
+	}
+}
+
+// pipeClient connects a client over net.Pipe, broker is handed the other end after the client
+// has sent CONNECT and been accepted
+func pipeClient(t *testing.T, opts Options, broker func(conn net.Conn, reader *bufio.Reader)) *Client {
+	clientConn, brokerConn := net.Pipe()
+	t.Cleanup(func() { brokerConn.Close() })
+	go func() {
+		reader := bufio.NewReader(brokerConn)
+		header, _, err := readPacket(reader)
+		if err != nil || header>>4 != packetConnect {
+			t.Errorf("broker expected CONNECT, got header %#x: %v", header, err)
+			return
+		}
+		packet := appendLength([]byte{packetConnack << 4}, 2)
+		_, err = brokerConn.Write(append(packet, 0, 0))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		broker(brokerConn, reader)
+	}()
+	client, err := newClient(clientConn, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func writePacket(t *testing.T, conn net.Conn, header byte, body []byte) {
+	packet := appendLength([]byte{header}, len(body))
+	_, err := conn.Write(append(packet, body...))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPipeRoundTrip(t *testing.T) {
+	published := make(chan []byte, 1)
+	messages := make(chan Message, 1)
+	client := pipeClient(t, Options{ClientId: "deck", OnMessage: func(message Message) {
+		messages <- message
+	}}, func(conn net.Conn, reader *bufio.Reader) {
+		for {
+			header, body, err := readPacket(reader)
+			if err != nil {
+				return
+			}
+			switch header >> 4 {
+			case packetSubscribe:
+				writePacket(t, conn, packetSuback<<4, append(body[:2:2], 0))
+				writePacket(t, conn, packetPublish<<4, append(appendString(nil, "lights/desk"), "on"...))
+			case packetPublish:
+				published <- body
+			case packetDisconnect:
+				return
+			}
+		}
+	})
+
+	err := client.Subscribe(map[string]byte{"lights/+": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := <-messages
+	if !MatchTopic("lights/+", message.Topic) || string(message.Payload) != "on" || message.Retained {
+		t.Errorf("got message %+v", message)
+	}
+
+	err = client.Publish("decks/deck", []byte("pressed"), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic, payload, err := readString(<-published)
+	if err != nil || topic != "decks/deck" || string(payload) != "pressed" {
+		t.Errorf("broker got %q on %s: %v", payload, topic, err)
+	}
+}
+
+func TestPacketTooLarge(t *testing.T) {
+	client := pipeClient(t, Options{ClientId: "deck"}, func(conn net.Conn, reader *bufio.Reader) {
+		// only the header is sent, the client has to give up before reading the body
+		conn.Write(appendLength([]byte{packetPublish << 4}, maxIncomingBytes+1))
+		io.Copy(io.Discard, reader)
+	})
+	select {
+	case <-client.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection wasn't dropped after an oversized packet")
+	}
+	if err := client.Err(); !errors.Is(err, ErrPacketTooLarge) {
+		t.Errorf("got %v, want %v", err, ErrPacketTooLarge)
+	}
+}
+
+func TestRemainingLength(t *testing.T) {
+	tests := []struct {
+		length  int
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097151, []byte{0xff, 0xff, 0x7f}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+		{maxRemainingBytes, []byte{0xff, 0xff, 0xff, 0x7f}},
+	}
+	for _, test := range tests {
+		encoded := appendLength(nil, test.length)
+		if !bytes.Equal(encoded, test.encoded) {
+			t.Errorf("%d encoded as %x, want %x", test.length, encoded, test.encoded)
+		}
+	}
+
+	for _, length := range []int{0, 5, 200, 20000} {
+		packet := appendLength([]byte{packetPublish << 4}, length)
+		packet = append(packet, make([]byte, length)...)
+		header, body, err := readPacket(bufio.NewReader(bytes.NewReader(packet)))
+		if err != nil || header != packetPublish<<4 || len(body) != length {
+			t.Errorf("read back header %#x and %d bytes, want %d: %v", header, len(body), length, err)
+		}
+	}
+
+	malformed := []byte{packetPublish << 4, 0xff, 0xff, 0xff, 0xff, 0x7f}
+	_, _, err := readPacket(bufio.NewReader(bytes.NewReader(malformed)))
+	if err == nil {
+		t.Error("read a remaining length longer than 4 bytes")
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter string
+		topic  string
+		want   bool
+	}{
+		{"lights/desk", "lights/desk", true},
+		{"lights/desk", "lights/hall", false},
+		{"lights/+", "lights/desk", true},
+		{"lights/+", "lights/desk/brightness", false},
+		{"lights/+/brightness", "lights/desk/brightness", true},
+		{"+/+", "lights/desk", true},
+		{"lights/#", "lights", true},
+		{"lights/#", "lights/desk/brightness", true},
+		{"#", "lights/desk", true},
+		{"lights", "lights/desk", false},
+		{"lights/desk", "lights", false},
+		{"#", "$SYS/broker/uptime", false},
+		{"+/broker/uptime", "$SYS/broker/uptime", false},
+		{"$SYS/#", "$SYS/broker/uptime", true},
+		{"$SYS/broker/+", "$SYS/broker/uptime", true},
+	}
+	for _, test := range tests {
+		if got := MatchTopic(test.filter, test.topic); got != test.want {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", test.filter, test.topic, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package streamdeckd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/unix-streamdeck/api/v2"
+)
+
+// KeyLookV1 is how a key looks, shown in place of the key's own icon and text
+type KeyLookV1 struct {
+	Icon          string                `json:"icon,omitempty"`
+	Text          string                `json:"text,omitempty"`
+	TextSize      int                   `json:"text_size,omitempty"`
+	TextAlignment api.VerticalAlignment `json:"text_alignment,omitempty"`
+	FontFace      string                `json:"font_face,omitempty"`
+	TextColour    string                `json:"text_colour,omitempty"`
+}
+
+func (l *KeyLookV1) GetIcon() string {
+	return l.Icon
+}
+
+func (l *KeyLookV1) GetText() string {
+	return l.Text
+}
+
+func (l *KeyLookV1) GetTextSize() int {
+	return l.TextSize
+}
+
+func (l *KeyLookV1) GetTextAlignment() api.VerticalAlignment {
+	return l.TextAlignment
+}
+
+func (l *KeyLookV1) GetFontFace() string {
+	return l.FontFace
+}
+
+func (l *KeyLookV1) GetTextColour() string {
+	return l.TextColour
+}
+
+// KeyStateV1 is one of the states of a multi-state key, its actions run when the key is
+// pressed while in this state
+type KeyStateV1 struct {
+	KeyLookV1
+	ActionV1
+}
+
+type IMultiStateKeys interface {
+	Current(keyIndex int, page int, application string) *KeyStateV1
+	Press(keyIndex int, page int, application string)
+	Set(keyIndex int, page int, application string, state int) error
+	AttachPageChangeListener()
+}
+
+type MultiStateKeys struct {
+	vdev IVirtualDev
+}
+
+func multiStateKeyId(keyIndex int, page int, application string) string {
+	return fmt.Sprintf("%d/%d/%s", page, keyIndex, application)
+}
+
+func (m *MultiStateKeys) keyExt(keyIndex int, page int, application string) *KeyConfigExtV3 {
+	keyExt := findDeckExt(m.vdev.Serial()).Page(page).Key(keyIndex, application)
+	if keyExt == nil || len(keyExt.States) == 0 {
+		return nil
+	}
+	return keyExt
+}
+
+func (m *MultiStateKeys) index(keyExt *KeyConfigExtV3, keyIndex int, page int, application string) int {
+	stateSem.Lock()
+	defer stateSem.Unlock()
+	deckState := state.Decks[m.vdev.Serial()]
+	if deckState == nil {
+		return 0
+	}
+	index := deckState.KeyStates[multiStateKeyId(keyIndex, page, application)]
+	if index < 0 || index >= len(keyExt.States) {
+		return 0
+	}
+	return index
+}
+
+// Current returns the state a key is showing, or nil if it isn't a multi-state key
+func (m *MultiStateKeys) Current(keyIndex int, page int, application string) *KeyStateV1 {
+	keyExt := m.keyExt(keyIndex, page, application)
+	if keyExt == nil {
+		return nil
+	}
+	return &keyExt.States[m.index(keyExt, keyIndex, page, application)]
+}
+
+// Press runs the actions of the current state, then moves on to the next
+func (m *MultiStateKeys) Press(keyIndex int, page int, application string) {
+	keyExt := m.keyExt(keyIndex, page, application)
+	if keyExt == nil {
+		return
+	}
+	index := m.index(keyExt, keyIndex, page, application)
+	current := &keyExt.States[index]
+	m.vdev.InputManager().RunAction(&current.ActionV1)
+	err := m.Set(keyIndex, page, application, (index+1)%len(keyExt.States))
+	if err != nil {
+		m.vdev.Logger().Println(err)
+	}
+}
+
+func (m *MultiStateKeys) Set(keyIndex int, page int, application string, index int) error {
+	keyExt := m.keyExt(keyIndex, page, application)
+	if keyExt == nil {
+		return errors.New("key is not a multi-state key")
+	}
+	if index < 0 || index >= len(keyExt.States) {
+		return fmt.Errorf("key only has %d states", len(keyExt.States))
+	}
+	updateDeckState(m.vdev.Serial(), func(deckState *DeckStateV1) {
+		if deckState.KeyStates == nil {
+			deckState.KeyStates = make(map[string]int)
+		}
+		deckState.KeyStates[multiStateKeyId(keyIndex, page, application)] = index
+	})
+	err := SaveState()
+	if err != nil {
+		m.vdev.Logger().Println(err)
+	}
+	m.redraw(keyIndex, page, application)
+	return nil
+}
+
+func (m *MultiStateKeys) redraw(keyIndex int, page int, application string) {
+	pages := m.vdev.Config().Pages
+	if page >= len(pages) || keyIndex >= len(pages[page].Keys) {
+		return
+	}
+	keyConfig, ok := pages[page].Keys[keyIndex].Application[application]
+	if !ok {
+		return
+	}
+	m.vdev.Foregrounder().SetKey(keyConfig, keyIndex, page, application)
+}
+
+// syncFromCommand runs a key's state_command, and shows the state matching its exit code
+func (m *MultiStateKeys) syncFromCommand(keyIndex int, page int, application string, command string) {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		m.vdev.Logger().Println("State command", command, "failed:", err)
+		return
+	}
+	err = m.Set(keyIndex, page, application, cmd.ProcessState.ExitCode())
+	if err != nil {
+		m.vdev.Logger().Println("State command", command, "exit code has no matching state:", err)
+	}
+}
+
+func (m *MultiStateKeys) AttachPageChangeListener() {
+	m.vdev.PageManager().AttachListener(func(newPage, _ int) {
+		page := m.vdev.Config().Pages[newPage]
+		for i := range page.Keys {
+			application := keyApplication(&page.Keys[i])
+			keyExt := m.keyExt(i, newPage, application)
+			if keyExt != nil && keyExt.StateCommand != "" {
+				go m.syncFromCommand(i, newPage, application, keyExt.StateCommand)
+			}
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"log"
 	"strconv"
+	"sync"
 
 	obsws "github.com/christopher-dG/go-obs-websocket"
 	"github.com/unix-streamdeck/api/v2"
@@ -69,7 +70,34 @@ var paramlessObsCommands = map[string]func() obsws.Request{
 }
 var obs obsws.Client
 
+var obsState struct {
+	mu        sync.Mutex
+	streaming bool
+	recording bool
+}
+
+// obsConnectSem stops two callers connecting the shared client at once
+var obsConnectSem sync.Mutex
+
+// tryConnectObs connects to OBS, replacing any connection there already is
 func tryConnectObs() error {
+	obsConnectSem.Lock()
+	defer obsConnectSem.Unlock()
+	return dialObs()
+}
+
+// connectObs connects to OBS if it isn't already connected
+func connectObs() error {
+	obsConnectSem.Lock()
+	defer obsConnectSem.Unlock()
+	if obs.Connected() {
+		return nil
+	}
+	return dialObs()
+}
+
+// dialObs must be called with obsConnectSem held
+func dialObs() error {
 	if config.ObsConnectionInfo.Host != "" && config.ObsConnectionInfo.Port != 0 {
 		log.Println("Found connection info")
 		obs = obsws.Client{Host: config.ObsConnectionInfo.Host, Port: config.ObsConnectionInfo.Port}
@@ -77,19 +105,78 @@ func tryConnectObs() error {
 		if err := obs.Connect(); err != nil {
 			return err
 		}
+		watchObs()
 	} else {
 		return errors.New("No Obs Connection Info Provided")
 	}
 	return nil
 }
 
-func runObsCommand(command string, params map[string]string) {
-	if !obs.Connected() {
-		err := tryConnectObs()
+// watchObs keeps track of whether OBS is streaming or recording, and fires obs rules, the
+// client forgets its event handlers when it reconnects, so this runs after every connect
+func watchObs() {
+	request := obsws.NewGetStreamingStatusRequest()
+	if err := request.Send(obs); err != nil {
+		log.Println(err)
+	} else if resp, err := request.Receive(); err != nil {
+		log.Println(err)
+	} else {
+		setObsStatus(resp.Streaming, resp.Recording)
+	}
+	handlers := map[string]func(obsws.Event){
+		"StreamStarted": func(obsws.Event) {
+			_, recording := obsStatus()
+			setObsStatus(true, recording)
+			fireRules(ruleEvent{kind: RuleObs, obs: "stream_started"})
+		},
+		"StreamStopped": func(obsws.Event) {
+			_, recording := obsStatus()
+			setObsStatus(false, recording)
+			fireRules(ruleEvent{kind: RuleObs, obs: "stream_stopped"})
+		},
+		"RecordingStarted": func(obsws.Event) {
+			streaming, _ := obsStatus()
+			setObsStatus(streaming, true)
+			fireRules(ruleEvent{kind: RuleObs, obs: "recording_started"})
+		},
+		"RecordingStopped": func(obsws.Event) {
+			streaming, _ := obsStatus()
+			setObsStatus(streaming, false)
+			fireRules(ruleEvent{kind: RuleObs, obs: "recording_stopped"})
+		},
+		"SwitchScenes": func(event obsws.Event) {
+			if switched, ok := event.(obsws.SwitchScenesEvent); ok {
+				fireRules(ruleEvent{kind: RuleObs, obs: "scene_changed", scene: switched.SceneName})
+			}
+		},
+	}
+	for eventType, handler := range handlers {
+		err := obs.AddEventHandler(eventType, handler)
 		if err != nil {
 			log.Println(err)
 		}
 	}
+}
+
+func setObsStatus(streaming bool, recording bool) {
+	obsState.mu.Lock()
+	defer obsState.mu.Unlock()
+	obsState.streaming = streaming
+	obsState.recording = recording
+}
+
+// obsStatus returns whether OBS is streaming and recording, as of the last time it was connected
+func obsStatus() (bool, bool) {
+	obsState.mu.Lock()
+	defer obsState.mu.Unlock()
+	return obsState.streaming, obsState.recording
+}
+
+func runObsCommand(command string, params map[string]string) {
+	err := connectObs()
+	if err != nil {
+		log.Println(err)
+	}
 	var req obsws.Request
 	requestLambda, exists := paramlessObsCommands[command]
 
@@ -162,11 +249,9 @@ func getObsHandlerFields() ([]api.Module, error) {
 
 	var sources []string
 
-	if !obs.Connected() {
-		err := tryConnectObs()
-		if err != nil {
-			return nil, err
-		}
+	err := connectObs()
+	if err != nil {
+		return nil, err
 	}
 
 	request := obsws.NewGetSpecialSourcesRequest()
@@ -0,0 +1,215 @@
+package streamdeckd
+
+import (
+	"image"
+	"sync"
+
+	"github.com/unix-streamdeck/api/v2"
+)
+
+const defaultPageCacheBudget = 32
+
+type PageCacheV1 struct {
+	KeepHandlers bool `json:"keep_handlers,omitempty"`
+	// MemoryBudget is the most the cached images for a deck may take up, in MiB
+	MemoryBudget int `json:"memory_budget,omitempty"`
+}
+
+type IPageCache interface {
+	StoreKey(img image.Image, keyIndex int, page int, application string)
+	StorePanel(img image.Image, knobIndex int, page int, application string)
+	Key(keyIndex int, page int, application string) image.Image
+	Panel(knobIndex int, page int, application string) image.Image
+	KeepsHandlers() bool
+	Wants(page int) bool
+	Clear()
+	ClearPage(page int)
+	AttachPageChangeListener()
+}
+
+type cachedImage struct {
+	img         image.Image
+	application string
+}
+
+type cachedPage struct {
+	keys   map[int]cachedImage
+	panels map[int]cachedImage
+}
+
+type PageCache struct {
+	vdev  IVirtualDev
+	mu    sync.Mutex
+	pages map[int]*cachedPage
+	size  int
+}
+
+func (pc *PageCache) settings() *PageCacheV1 {
+	deckExt := findDeckExt(pc.vdev.Serial())
+	if deckExt == nil {
+		return nil
+	}
+	return deckExt.PageCache
+}
+
+func (pc *PageCache) KeepsHandlers() bool {
+	settings := pc.settings()
+	return settings != nil && settings.KeepHandlers
+}
+
+func (pc *PageCache) budget() int {
+	settings := pc.settings()
+	if settings == nil {
+		return 0
+	}
+	if settings.MemoryBudget == 0 {
+		return defaultPageCacheBudget * 1024 * 1024
+	}
+	return settings.MemoryBudget * 1024 * 1024
+}
+
+// Wants reports if images drawn for page would be cached, so work on pages that aren't showing
+// can be skipped when they won't be
+func (pc *PageCache) Wants(page int) bool {
+	return pc.budget() != 0 && pc.inWindow(page)
+}
+
+func (pc *PageCache) StoreKey(img image.Image, keyIndex int, page int, application string) {
+	pc.store(img, keyIndex, page, application, false)
+}
+
+func (pc *PageCache) StorePanel(img image.Image, knobIndex int, page int, application string) {
+	pc.store(img, knobIndex, page, application, true)
+}
+
+func (pc *PageCache) store(img image.Image, index int, page int, application string, panel bool) {
+	budget := pc.budget()
+	if img == nil || !pc.Wants(page) {
+		return
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if pc.pages == nil {
+		pc.pages = make(map[int]*cachedPage)
+	}
+	cached, ok := pc.pages[page]
+	if !ok {
+		cached = &cachedPage{keys: make(map[int]cachedImage), panels: make(map[int]cachedImage)}
+		pc.pages[page] = cached
+	}
+	images := cached.keys
+	if panel {
+		images = cached.panels
+	}
+
+	size := imageSize(img)
+	if previous, ok := images[index]; ok {
+		size -= imageSize(previous.img)
+	}
+	if pc.size+size > budget {
+		pc.vdev.Logger().Println("Page cache is over its memory budget, not caching page", page)
+		return
+	}
+	pc.size += size
+	images[index] = cachedImage{img: img, application: application}
+}
+
+func (pc *PageCache) Key(keyIndex int, page int, application string) image.Image {
+	return pc.lookup(keyIndex, page, application, false)
+}
+
+func (pc *PageCache) Panel(knobIndex int, page int, application string) image.Image {
+	return pc.lookup(knobIndex, page, application, true)
+}
+
+func (pc *PageCache) lookup(index int, page int, application string, panel bool) image.Image {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	cached, ok := pc.pages[page]
+	if !ok {
+		return nil
+	}
+	images := cached.keys
+	if panel {
+		images = cached.panels
+	}
+	entry, ok := images[index]
+	if !ok || entry.application != application {
+		return nil
+	}
+	return entry.img
+}
+
+func (pc *PageCache) Clear() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.pages = nil
+	pc.size = 0
+}
+
+// ClearPage drops the images cached for one page, for pages whose keys have been replaced
+func (pc *PageCache) ClearPage(page int) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.dropPage(page)
+}
+
+// dropPage must be called with the lock held
+func (pc *PageCache) dropPage(page int) {
+	cached, ok := pc.pages[page]
+	if !ok {
+		return
+	}
+	for _, entry := range cached.keys {
+		pc.size -= imageSize(entry.img)
+	}
+	for _, entry := range cached.panels {
+		pc.size -= imageSize(entry.img)
+	}
+	delete(pc.pages, page)
+}
+
+// inWindow reports if page is the current page, or either side of it
+func (pc *PageCache) inWindow(page int) bool {
+	current := pc.vdev.PageManager().GetPage()
+	return page >= current-1 && page <= current+1
+}
+
+func (pc *PageCache) AttachPageChangeListener() {
+	pc.vdev.PageManager().AttachListener(func(newPage, _ int) {
+		if pc.settings() == nil {
+			return
+		}
+
+		pc.mu.Lock()
+		for page := range pc.pages {
+			if page >= newPage-1 && page <= newPage+1 {
+				continue
+			}
+			pc.dropPage(page)
+		}
+		pc.mu.Unlock()
+
+		for _, page := range []int{newPage - 1, newPage + 1} {
+			if page >= 0 && page < len(pc.vdev.Config().Pages) {
+				go pc.vdev.Foregrounder().Preload(page)
+			}
+		}
+	})
+}
+
+func imageSize(img image.Image) int {
+	return img.Bounds().Dx() * img.Bounds().Dy() * 4
+}
+
+// keyApplication is the application entry a key will show for the focused window, the default
+// if none of its entries match
+func keyApplication(key *api.KeyV3) string {
+	return matchApplication(key.Application, applicationManager.GetContext())
+}
+
+func knobApplication(knob *api.KnobV3) string {
+	return matchApplication(knob.Application, applicationManager.GetContext())
+}
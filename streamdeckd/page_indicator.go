@@ -0,0 +1,169 @@
+package streamdeckd
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/unix-streamdeck/api/v2"
+)
+
+type PageIndicatorStyle string
+
+const (
+	PageIndicatorDots PageIndicatorStyle = "dots"
+	PageIndicatorText PageIndicatorStyle = "text"
+)
+
+type PageIndicatorV1 struct {
+	Style     PageIndicatorStyle    `json:"style,omitempty"`
+	Key       *int                  `json:"key,omitempty"`
+	Colour    string                `json:"colour,omitempty"`
+	TextSize  int                   `json:"text_size,omitempty"`
+	Alignment api.VerticalAlignment `json:"alignment,omitempty"`
+}
+
+type IPageIndicator interface {
+	AttachPageChangeListener()
+}
+
+type PageIndicator struct {
+	vdev      IVirtualDev
+	activeKey int
+}
+
+func (pi *PageIndicator) AttachPageChangeListener() {
+	pi.vdev.PageManager().AttachListener(func(newPage, _ int) {
+		pi.draw(newPage)
+	})
+}
+
+func (pi *PageIndicator) draw(page int) {
+	deckExt := findDeckExt(pi.vdev.Serial())
+	pageCount := len(pi.vdev.Config().Pages)
+	// the generated page isn't one of the configured pages, so it isn't counted or shown
+	slot := pi.vdev.DynamicPager().Slot()
+	if slot >= 0 && slot < pageCount {
+		pageCount = slot
+	}
+
+	if deckExt == nil || deckExt.PageIndicator == nil || pageCount < 2 || page == slot {
+		pi.clear()
+		return
+	}
+
+	settings := deckExt.PageIndicator
+	info := pi.vdev.SdInfo()
+
+	name := ""
+	if pageExt := deckExt.Page(page); pageExt != nil {
+		name = pageExt.Name
+	}
+
+	if settings.Key != nil {
+		keyIndex := *settings.Key
+		if keyIndex < 0 || keyIndex >= info.Cols*info.Rows {
+			pi.vdev.Logger().Printf("Page indicator key %d is out of range\n", keyIndex)
+			pi.clear()
+			return
+		}
+		img, err := pi.render(settings, name, page, pageCount, info.IconSize, info.IconSize)
+		if err != nil {
+			pi.vdev.Logger().Println(err)
+			return
+		}
+		if pi.activeKey >= 0 && pi.activeKey != keyIndex {
+			pi.vdev.SetKeyOverlay(nil, pi.activeKey, IndicatorLayer)
+		}
+		pi.activeKey = keyIndex
+		pi.vdev.SetKeyOverlay(img, keyIndex, IndicatorLayer)
+		return
+	}
+
+	if info.LcdCols == 0 {
+		pi.vdev.Logger().Println("Page indicator needs a key set on decks without an LCD")
+		return
+	}
+
+	img, err := pi.render(settings, name, page, pageCount, info.LcdBackgroundWidth, info.LcdBackgroundHeight)
+	if err != nil {
+		pi.vdev.Logger().Println(err)
+		return
+	}
+	for i, segment := range info.SplitBackgroundImage(img, api.LCD) {
+		pi.vdev.SetPanelOverlay(segment, i, IndicatorLayer)
+	}
+}
+
+func (pi *PageIndicator) clear() {
+	if pi.activeKey >= 0 {
+		pi.vdev.SetKeyOverlay(nil, pi.activeKey, IndicatorLayer)
+		pi.activeKey = -1
+	}
+	for i := 0; i < pi.vdev.SdInfo().LcdCols; i++ {
+		pi.vdev.SetPanelOverlay(nil, i, IndicatorLayer)
+	}
+}
+
+func (pi *PageIndicator) render(settings *PageIndicatorV1, name string, page, pageCount, w, h int) (image.Image, error) {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	colour := settings.Colour
+	if colour == "" {
+		colour = "#FFFFFF"
+	}
+
+	alignment := settings.Alignment
+	if alignment == "" {
+		alignment = api.Bottom
+	}
+
+	if settings.Style == PageIndicatorText {
+		text := name
+		if text == "" {
+			text = fmt.Sprintf("%d/%d", page+1, pageCount)
+		}
+		textSize := settings.TextSize
+		if textSize == 0 {
+			textSize = h / 5
+		}
+		return api.DrawText(img, text, api.DrawTextOptions{
+			FontSize:            int64(textSize),
+			VerticalAlignment:   alignment,
+			HorizontalAlignment: api.Middle,
+			Colour:              colour,
+		})
+	}
+
+	radius := max(h/24, 2)
+	spacing := radius * 3
+	x := (w - (pageCount-1)*spacing) / 2
+	y := h - radius*2
+	if alignment == api.Top {
+		y = radius * 2
+	} else if alignment == api.Center {
+		y = h / 2
+	}
+
+	active := api.HexColor(colour)
+	inactive := color.RGBA{R: active.R / 3, G: active.G / 3, B: active.B / 3, A: 0x55}
+
+	for i := 0; i < pageCount; i++ {
+		dotColour := inactive
+		if i == page {
+			dotColour = active
+		}
+		drawDot(img, x+i*spacing, y, radius, dotColour)
+	}
+	return img, nil
+}
+
+func drawDot(img *image.RGBA, cx, cy, radius int, c color.RGBA) {
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if x*x+y*y <= radius*radius {
+				img.SetRGBA(cx+x, cy+y, c)
+			}
+		}
+	}
+}
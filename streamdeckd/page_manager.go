@@ -24,6 +24,8 @@ func (pm *PageManager) SetPage(page int) {
 		pm.vdev.SdInfo().Page = page
 		EmitPage(pm.vdev, page)
 
+		pm.vdev.UpdateBrightness()
+
 		for _, listener := range pm.listeners {
 			go listener(pm.page, oldPage)
 		}
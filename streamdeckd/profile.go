@@ -0,0 +1,112 @@
+package streamdeckd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultProfile is the config file given on the command line, or the default location
+const defaultProfile = "default"
+
+var defaultConfigPath string
+var activeProfile = defaultProfile
+
+// profilesDir holds a config file for each profile other than the default, named after it
+func profilesDir() string {
+	return filepath.Join(filepath.Dir(defaultConfigPath), "streamdeckd-profiles")
+}
+
+// Profiles lists the default profile, followed by the profiles in the profiles directory
+func Profiles() []string {
+	profiles := []string{defaultProfile}
+	entries, err := os.ReadDir(profilesDir())
+	if err != nil && !os.IsNotExist(err) {
+		log.Println(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if ok && !entry.IsDir() && name != defaultProfile {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return append(profiles, names...)
+}
+
+func ActiveProfile() string {
+	configSem.Lock()
+	defer configSem.Unlock()
+	return activeProfile
+}
+
+func profilePath(name string) (string, error) {
+	if name == defaultProfile {
+		return defaultConfigPath, nil
+	}
+	if name == "" || strings.ContainsRune(name, os.PathSeparator) {
+		return "", fmt.Errorf("invalid profile name %q", name)
+	}
+	path := filepath.Join(profilesDir(), name+".json")
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("profile %s does not exist", name)
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// useProfile points the config path at a profile, without loading it, it must be called with
+// configSem held
+func useProfile(name string) error {
+	path, err := profilePath(name)
+	if err != nil {
+		return err
+	}
+	configPath = path
+	activeProfile = name
+	return nil
+}
+
+// SwitchProfile loads a profile's config file in place of the running config, and remembers it
+// for the next start
+func SwitchProfile(name string) error {
+	configSem.Lock()
+	defer configSem.Unlock()
+	if name == activeProfile {
+		return nil
+	}
+	err := useProfile(name)
+	if err != nil {
+		return err
+	}
+	log.Println("Switching to profile", name)
+	stateSem.Lock()
+	state.Profile = name
+	if name == defaultProfile {
+		state.Profile = ""
+	}
+	stateSem.Unlock()
+	err = SaveState()
+	if err != nil {
+		log.Println(err)
+	}
+	return reloadConfig()
+}
+
+// CycleProfile switches to the profile step places after the active one, wrapping around
+func CycleProfile(step int) error {
+	profiles := Profiles()
+	if len(profiles) < 2 {
+		return errors.New("no profiles to switch to")
+	}
+	index := max(slices.Index(profiles, ActiveProfile()), 0)
+	next := ((index+step)%len(profiles) + len(profiles)) % len(profiles)
+	return SwitchProfile(profiles[next])
+}
@@ -0,0 +1,346 @@
+package streamdeckd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RuleApplication  = "application"
+	RulePage         = "page"
+	RuleConnect      = "connect"
+	RuleDisconnect   = "disconnect"
+	RuleScreenLock   = "screen_lock"
+	RuleScreenUnlock = "screen_unlock"
+	RuleTime         = "time"
+	RuleObs          = "obs"
+	RuleCustom       = "custom"
+)
+
+// RuleV1 reacts to something happening outside a deck's own input, running actions, or
+// switching profile, page or brightness
+type RuleV1 struct {
+	Name       string            `json:"name,omitempty"`
+	Trigger    RuleTriggerV1     `json:"trigger"`
+	Conditions *RuleConditionsV1 `json:"conditions,omitempty"`
+	// Serial is the deck switch_page and brightness change, defaults to the deck the trigger
+	// came from, or every connected deck
+	Serial string `json:"serial,omitempty"`
+	// Profile is switched to before anything else runs
+	Profile string `json:"profile,omitempty"`
+	ActionV1
+	// Brightness takes the place of the action's, so a rule can turn a deck's brightness down to 0
+	Brightness *int       `json:"brightness,omitempty"`
+	Actions    []ActionV1 `json:"actions,omitempty"`
+}
+
+// RuleTriggerV1 is what runs a rule, Event is one of the Rule constants, the other fields
+// narrow down which events of that kind run it
+type RuleTriggerV1 struct {
+	Event string `json:"event"`
+	// Application is an application entry, as the keys of a key's application map
+	Application string `json:"application,omitempty"`
+	// Serial limits page, connect and disconnect triggers to one deck
+	Serial string `json:"serial,omitempty"`
+	// Page limits page triggers to one page, counting from 1 as switch_page does
+	Page int `json:"page,omitempty"`
+	// At is the time of day of time triggers, as HH:MM
+	At string `json:"at,omitempty"`
+	at int
+	// Obs is stream_started, stream_stopped, recording_started, recording_stopped or
+	// scene_changed
+	Obs string `json:"obs,omitempty"`
+	// Scene limits scene_changed to one scene
+	Scene string `json:"scene,omitempty"`
+	// Name is the name of custom events, fired with the D-Bus FireEvent method
+	Name string `json:"name,omitempty"`
+}
+
+// RuleConditionsV1 must all hold for a triggered rule to run
+type RuleConditionsV1 struct {
+	// Applications are application entries, one of which the focused application must match
+	Applications []string `json:"applications,omitempty"`
+	// After and Before are times of day, as HH:MM, After may be later than Before to span
+	// midnight
+	After  string `json:"after,omitempty"`
+	Before string `json:"before,omitempty"`
+	after  int
+	before int
+	// Days are the first three letters of the days of the week, e.g. mon
+	Days         []string `json:"days,omitempty"`
+	ScreenLocked *bool    `json:"screen_locked,omitempty"`
+	ObsStreaming *bool    `json:"obs_streaming,omitempty"`
+	ObsRecording *bool    `json:"obs_recording,omitempty"`
+	Profile      string   `json:"profile,omitempty"`
+}
+
+type ruleEvent struct {
+	kind   string
+	serial string
+	page   int
+	// previous is the application that was focused before, for application events
+	previous    ApplicationContext
+	application ApplicationContext
+	// minute is the time of day of time events, in minutes since midnight
+	minute int
+	obs    string
+	scene  string
+	name   string
+}
+
+var rulesOnce sync.Once
+
+// StartRules attaches the rules to the application manager and starts the clock for time
+// triggers, the other triggers are fired where they happen
+func StartRules() {
+	rulesOnce.Do(func() {
+		previous := applicationManager.GetContext()
+		var mu sync.Mutex
+		applicationManager.AttachListener(func(context ApplicationContext) {
+			mu.Lock()
+			event := ruleEvent{kind: RuleApplication, previous: previous, application: context}
+			previous = context
+			mu.Unlock()
+			fireRules(event)
+		})
+		go runRuleClock()
+	})
+}
+
+// runRuleClock fires a time event at the start of every minute, and reconnects to OBS for
+// rules waiting on it
+func runRuleClock() {
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		fireRules(ruleEvent{kind: RuleTime, minute: minuteOfDay(time.Now())})
+		if rulesUseObs() {
+			err := connectObs()
+			if err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// fireRules runs every rule event triggers, on its own goroutine, so triggers can fire from
+// listeners without waiting on the rule's changes, or on the config lock they may be called with
+func fireRules(event ruleEvent) {
+	go func() {
+		rules := configuredRules()
+		for i := range rules {
+			rule := &rules[i]
+			if rule.Trigger.matches(event) && rule.Conditions.hold(time.Now()) {
+				rule.run(event)
+			}
+		}
+	}()
+}
+
+// configuredRules returns the rules of the running config, which SetConfig may swap out while
+// rules are firing
+func configuredRules() []RuleV1 {
+	configSem.Lock()
+	defer configSem.Unlock()
+	if configExt == nil {
+		return nil
+	}
+	return configExt.Rules
+}
+
+func rulesUseObs() bool {
+	for _, rule := range configuredRules() {
+		if rule.Trigger.Event == RuleObs {
+			return true
+		}
+		if c := rule.Conditions; c != nil && (c.ObsStreaming != nil || c.ObsRecording != nil) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *RuleTriggerV1) matches(event ruleEvent) bool {
+	if t.Event != event.kind {
+		return false
+	}
+	if t.Serial != "" && event.serial != "" && t.Serial != event.serial {
+		return false
+	}
+	switch event.kind {
+	case RuleApplication:
+		if t.Application == "" {
+			return event.application.Class != event.previous.Class
+		}
+		entry := []string{t.Application}
+		return matchesAnyApplication(entry, event.application) && !matchesAnyApplication(entry, event.previous)
+	case RulePage:
+		return t.Page == 0 || t.Page-1 == event.page
+	case RuleTime:
+		return t.at == event.minute
+	case RuleObs:
+		return t.Obs == event.obs && (t.Scene == "" || t.Scene == event.scene)
+	case RuleCustom:
+		return t.Name == event.name
+	}
+	return true
+}
+
+func (c *RuleConditionsV1) hold(now time.Time) bool {
+	if c == nil {
+		return true
+	}
+	if len(c.Applications) > 0 && !matchesAnyApplication(c.Applications, applicationManager.GetContext()) {
+		return false
+	}
+	if !betweenTimes(minuteOfDay(now), c.after, c.before) {
+		return false
+	}
+	if len(c.Days) > 0 && !slices.ContainsFunc(c.Days, func(day string) bool {
+		return strings.EqualFold(day, now.Weekday().String()[:3])
+	}) {
+		return false
+	}
+	if c.ScreenLocked != nil && *c.ScreenLocked != locked {
+		return false
+	}
+	streaming, recording := obsStatus()
+	if c.ObsStreaming != nil && *c.ObsStreaming != streaming {
+		return false
+	}
+	if c.ObsRecording != nil && *c.ObsRecording != recording {
+		return false
+	}
+	return c.Profile == "" || c.Profile == ActiveProfile()
+}
+
+// betweenTimes compares times of day in minutes, either bound can be -1 to leave it out
+func betweenTimes(now, after, before int) bool {
+	switch {
+	case after < 0 && before < 0:
+		return true
+	case after < 0:
+		return now < before
+	case before < 0:
+		return now >= after
+	case after <= before:
+		return now >= after && now < before
+	default:
+		return now >= after || now < before
+	}
+}
+
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// parseTimeOfDay returns the minutes since midnight of an HH:MM time, or -1 if it is empty
+func parseTimeOfDay(value string) (int, error) {
+	if value == "" {
+		return -1, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return -1, fmt.Errorf("%q is not a time of day as HH:MM", value)
+	}
+	return minuteOfDay(t), nil
+}
+
+// parseRules reads the times of day of rules, a rule with a time that doesn't parse is an error
+// for the whole config
+func parseRules(rules []RuleV1) error {
+	var errs []error
+	for i := range rules {
+		err := rules[i].parseTimes()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", rules[i].name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *RuleV1) parseTimes() error {
+	var err error
+	r.Trigger.at, err = parseTimeOfDay(r.Trigger.At)
+	if err != nil {
+		return err
+	}
+	if r.Trigger.Event == RuleTime && r.Trigger.at < 0 {
+		return errors.New("time triggers need a time in at")
+	}
+	if r.Conditions == nil {
+		return nil
+	}
+	r.Conditions.after, err = parseTimeOfDay(r.Conditions.After)
+	if err != nil {
+		return err
+	}
+	r.Conditions.before, err = parseTimeOfDay(r.Conditions.Before)
+	return err
+}
+
+func (r *RuleV1) name() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Trigger.Event
+}
+
+func (r *RuleV1) run(event ruleEvent) {
+	log.Println("Running rule", r.name())
+	if r.Profile != "" {
+		err := SwitchProfile(r.Profile)
+		if err != nil {
+			log.Println(fmt.Sprintf("Rule %s could not switch profile: %s", r.name(), err))
+		}
+	}
+	devs := r.decks(event)
+	for _, dev := range devs {
+		if r.SwitchPage != 0 {
+			dev.PageManager().SetPage(r.SwitchPage - 1)
+		}
+		if r.Brightness != nil {
+			err := dev.SetBrightness(uint8(*r.Brightness))
+			if err != nil {
+				dev.Logger().Println(err)
+			}
+		}
+	}
+	// the rest of the actions aren't tied to a deck, so they run once, on any of them
+	if len(devs) == 0 {
+		for _, dev := range Devs {
+			devs = append(devs, dev)
+			break
+		}
+	}
+	if len(devs) == 0 {
+		log.Println("Rule", r.name(), "has no deck to run its actions on")
+		return
+	}
+	action := r.ActionV1
+	action.SwitchPage = 0
+	devs[0].InputManager().RunAction(&action)
+	if len(r.Actions) > 0 {
+		devs[0].InputManager().RunMacro("rule "+r.name(), r.Actions)
+	}
+}
+
+// decks returns the connected decks a rule changes the page and brightness of
+func (r *RuleV1) decks(event ruleEvent) []IVirtualDev {
+	serial := r.Serial
+	if serial == "" {
+		serial = event.serial
+	}
+	var devs []IVirtualDev
+	for _, dev := range Devs {
+		if dev.IsOpen() && (serial == "" || dev.Serial() == serial) {
+			devs = append(devs, dev)
+		}
+	}
+	return devs
+}
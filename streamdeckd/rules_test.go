@@ -0,0 +1,125 @@
+package streamdeckd
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseTimeOfDay(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: -1},
+		{value: "00:00", want: 0},
+		{value: "09:30", want: 570},
+		{value: "23:59", want: 1439},
+		{value: "24:00", wantErr: true},
+		{value: "9:30", want: 570},
+		{value: "12:60", wantErr: true},
+		{value: "noon", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseTimeOfDay(test.value)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q: got %d, want an error", test.value, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%q: got %d, want %d: %v", test.value, got, test.want, err)
+		}
+	}
+}
+
+func TestBetweenTimes(t *testing.T) {
+	tests := []struct {
+		now, after, before int
+		want               bool
+	}{
+		{now: 600, after: -1, before: -1, want: true},
+		{now: 600, after: 540, before: -1, want: true},
+		{now: 539, after: 540, before: -1, want: false},
+		{now: 600, after: -1, before: 600, want: false},
+		{now: 599, after: -1, before: 600, want: true},
+		{now: 540, after: 540, before: 1050, want: true},
+		{now: 1050, after: 540, before: 1050, want: false},
+		{now: 300, after: 540, before: 1050, want: false},
+		// spanning midnight
+		{now: 1380, after: 1320, before: 360, want: true},
+		{now: 60, after: 1320, before: 360, want: true},
+		{now: 720, after: 1320, before: 360, want: false},
+	}
+	for _, test := range tests {
+		if got := betweenTimes(test.now, test.after, test.before); got != test.want {
+			t.Errorf("betweenTimes(%d, %d, %d) = %v, want %v", test.now, test.after, test.before, got, test.want)
+		}
+	}
+}
+
+func TestRuleTriggerMatches(t *testing.T) {
+	firefox := ApplicationContext{Class: "firefox"}
+	terminal := ApplicationContext{Class: "kitty"}
+	tests := []struct {
+		name    string
+		trigger RuleTriggerV1
+		event   ruleEvent
+		want    bool
+	}{
+		{"other kind", RuleTriggerV1{Event: RuleConnect}, ruleEvent{kind: RuleDisconnect}, false},
+		{"any deck", RuleTriggerV1{Event: RuleConnect}, ruleEvent{kind: RuleConnect, serial: "A"}, true},
+		{"same deck", RuleTriggerV1{Event: RuleConnect, Serial: "A"}, ruleEvent{kind: RuleConnect, serial: "A"}, true},
+		{"other deck", RuleTriggerV1{Event: RuleConnect, Serial: "A"}, ruleEvent{kind: RuleConnect, serial: "B"}, false},
+		{"any page", RuleTriggerV1{Event: RulePage}, ruleEvent{kind: RulePage, page: 4}, true},
+		{"page counts from 1", RuleTriggerV1{Event: RulePage, Page: 2}, ruleEvent{kind: RulePage, page: 1}, true},
+		{"other page", RuleTriggerV1{Event: RulePage, Page: 2}, ruleEvent{kind: RulePage, page: 2}, false},
+		{"at the time", RuleTriggerV1{Event: RuleTime, at: 570}, ruleEvent{kind: RuleTime, minute: 570}, true},
+		{"another time", RuleTriggerV1{Event: RuleTime, at: 570}, ruleEvent{kind: RuleTime, minute: 571}, false},
+		{"any class change", RuleTriggerV1{Event: RuleApplication}, ruleEvent{kind: RuleApplication, previous: terminal, application: firefox}, true},
+		{"same class", RuleTriggerV1{Event: RuleApplication}, ruleEvent{kind: RuleApplication, previous: firefox, application: firefox}, false},
+		{"focus moves to application", RuleTriggerV1{Event: RuleApplication, Application: "firefox"}, ruleEvent{kind: RuleApplication, previous: terminal, application: firefox}, true},
+		{"focus moves away", RuleTriggerV1{Event: RuleApplication, Application: "firefox"}, ruleEvent{kind: RuleApplication, previous: firefox, application: terminal}, false},
+		{"obs event", RuleTriggerV1{Event: RuleObs, Obs: "stream_started"}, ruleEvent{kind: RuleObs, obs: "stream_started"}, true},
+		{"other obs event", RuleTriggerV1{Event: RuleObs, Obs: "stream_started"}, ruleEvent{kind: RuleObs, obs: "stream_stopped"}, false},
+		{"scene", RuleTriggerV1{Event: RuleObs, Obs: "scene_changed", Scene: "Live"}, ruleEvent{kind: RuleObs, obs: "scene_changed", scene: "Live"}, true},
+		{"other scene", RuleTriggerV1{Event: RuleObs, Obs: "scene_changed", Scene: "Live"}, ruleEvent{kind: RuleObs, obs: "scene_changed", scene: "Break"}, false},
+		{"custom", RuleTriggerV1{Event: RuleCustom, Name: "build"}, ruleEvent{kind: RuleCustom, name: "build"}, true},
+		{"other custom", RuleTriggerV1{Event: RuleCustom, Name: "build"}, ruleEvent{kind: RuleCustom, name: "deploy"}, false},
+	}
+	for _, test := range tests {
+		if got := test.trigger.matches(test.event); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	ext, err := parseConfigExt([]byte(`{"rules":[{"trigger":{"event":"time","at":"22:30"},"conditions":{"after":"22:00"},"brightness":0}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := ext.Rules[0]
+	if rule.Trigger.at != 1350 || rule.Conditions.after != 1320 || rule.Conditions.before != -1 {
+		t.Errorf("times parsed as %d, %d and %d", rule.Trigger.at, rule.Conditions.after, rule.Conditions.before)
+	}
+	if rule.Brightness == nil || *rule.Brightness != 0 {
+		t.Errorf("brightness of 0 was lost")
+	}
+	data, err := json.Marshal(rule)
+	if err != nil || string(data) != `{"trigger":{"event":"time","at":"22:30"},"conditions":{"after":"22:00"},"brightness":0}` {
+		t.Errorf("rule saved as %s: %v", data, err)
+	}
+
+	for _, config := range []string{
+		`{"rules":[{"trigger":{"event":"time"}}]}`,
+		`{"rules":[{"trigger":{"event":"time","at":"25:00"}}]}`,
+		`{"rules":[{"trigger":{"event":"custom","name":"x"},"conditions":{"before":"soon"}}]}`,
+	} {
+		_, err := parseConfigExt([]byte(config))
+		if err == nil {
+			t.Errorf("%s was loaded", config)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package streamdeckd
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// StateV1 is what streamdeckd remembers between runs that isn't configuration, it's kept
+// in its own file so the config file is only written when the config changes
+type StateV1 struct {
+	Decks map[string]*DeckStateV1 `json:"decks,omitempty"`
+	// Profile is the profile that was active, empty for the default
+	Profile string `json:"profile,omitempty"`
+}
+
+type DeckStateV1 struct {
+	// Brightness is nil if it has never been set, so a brightness of 0 is remembered
+	Brightness *int `json:"brightness,omitempty"`
+	// KeyStates holds the state of each multi-state key, by page/key/application
+	KeyStates map[string]int `json:"key_states,omitempty"`
+}
+
+var statePath string
+var state = &StateV1{}
+
+var stateSem sync.Mutex
+
+func LoadState() {
+	basePath := os.Getenv("HOME") + string(os.PathSeparator) + ".local" + string(os.PathSeparator) + "state"
+	if os.Getenv("XDG_STATE_HOME") != "" {
+		basePath = os.Getenv("XDG_STATE_HOME")
+	}
+	statePath = basePath + string(os.PathSeparator) + "streamdeckd-state.json"
+
+	profile := readState()
+	if profile == "" {
+		return
+	}
+	if configPathGiven {
+		log.Println("Using the config file given on the command line, not the last profile", profile)
+		return
+	}
+	configSem.Lock()
+	defer configSem.Unlock()
+	err := useProfile(profile)
+	if err != nil {
+		log.Println("Could not restore profile, using the default", err)
+	}
+}
+
+// readState loads the state saved by the last run, returning the profile it had switched to
+func readState() string {
+	stateSem.Lock()
+	defer stateSem.Unlock()
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return ""
+	}
+	var loaded StateV1
+	err = json.Unmarshal(data, &loaded)
+	if err != nil {
+		log.Println("Could not parse state, starting fresh", err)
+		return ""
+	}
+	state = &loaded
+	return state.Profile
+}
+
+func SaveState() error {
+	stateSem.Lock()
+	defer stateSem.Unlock()
+	if statePath == "" {
+		return nil
+	}
+	err := os.MkdirAll(filepath.Dir(statePath), 0755)
+	if err != nil {
+		return err
+	}
+	return SaveFile(statePath, state)
+}
+
+// updateDeckState changes the state of a deck under the state lock, creating it if needed
+func updateDeckState(serial string, update func(deckState *DeckStateV1)) {
+	stateSem.Lock()
+	defer stateSem.Unlock()
+	if state.Decks == nil {
+		state.Decks = make(map[string]*DeckStateV1)
+	}
+	deckState, ok := state.Decks[serial]
+	if !ok {
+		deckState = &DeckStateV1{}
+		state.Decks[serial] = deckState
+	}
+	update(deckState)
+}
+
+// savedBrightness returns the brightness last chosen for a deck, and whether one has been
+func savedBrightness(serial string) (int, bool) {
+	stateSem.Lock()
+	defer stateSem.Unlock()
+	if deckState := state.Decks[serial]; deckState != nil && deckState.Brightness != nil {
+		return *deckState.Brightness, true
+	}
+	return 0, false
+}
@@ -0,0 +1,97 @@
+package streamdeckd
+
+import (
+	"github.com/unix-streamdeck/api/v2"
+	streamdeck "github.com/unix-streamdeck/driver"
+)
+
+// SwipeV1 sets what swiping across the touch screen does
+type SwipeV1 struct {
+	// Mode is page (the default), profile, action or disabled
+	Mode        string    `json:"mode,omitempty"`
+	LeftAction  *ActionV1 `json:"left_action,omitempty"`
+	RightAction *ActionV1 `json:"right_action,omitempty"`
+}
+
+func (s *SwipeV1) mode() string {
+	if s == nil || s.Mode == "" {
+		return "page"
+	}
+	return s.Mode
+}
+
+// HandleSwipe reports a swipe to the handler of the segment it started on, then runs the
+// deck's swipe mode. Swiping left moves forwards, to the next page or profile
+func (im *InputManager) HandleSwipe(event streamdeck.InputEvent) {
+	distance := int(event.ScreenEndX) - int(event.ScreenX)
+	direction := "right"
+	if distance < 0 {
+		direction = "left"
+		distance = -distance
+	}
+	im.reportSwipe(event, direction, distance)
+
+	var swipe *SwipeV1
+	if deckExt := findDeckExt(im.vdev.Serial()); deckExt != nil {
+		swipe = deckExt.Swipe
+	}
+	switch swipe.mode() {
+	case "disabled":
+	case "action":
+		action := swipe.RightAction
+		if direction == "left" {
+			action = swipe.LeftAction
+		}
+		if action != nil {
+			im.RunAction(action)
+		}
+	case "profile":
+		step := -1
+		if direction == "left" {
+			step = 1
+		}
+		go func() {
+			err := CycleProfile(step)
+			if err != nil {
+				im.vdev.Logger().Println(err)
+			}
+		}()
+	case "page":
+		page := im.vdev.PageManager().GetPage()
+		if direction == "left" {
+			if page < len(im.vdev.Config().Pages)-1 {
+				im.vdev.PageManager().SetPage(page + 1)
+			}
+		} else if page > 0 {
+			im.vdev.PageManager().SetPage(page - 1)
+		}
+	default:
+		im.vdev.Logger().Println("Unknown swipe mode:", swipe.Mode)
+	}
+}
+
+// reportSwipe sends the swipe to an input handler, the api has no fields for swipes, so their
+// direction and distance in pixels are added to the handler's fields as swipe_direction and
+// swipe_distance
+func (im *InputManager) reportSwipe(event streamdeck.InputEvent, direction string, distance int) {
+	lcdWidth := im.vdev.SdInfo().LcdWidth
+	if lcdWidth == 0 {
+		return
+	}
+	index := int(event.ScreenX) / lcdWidth
+	pages := im.vdev.Config().Pages
+	page := im.vdev.PageManager().GetPage()
+	if page >= len(pages) || index >= len(pages[page].Knobs) {
+		return
+	}
+	knob := &pages[page].Knobs[index]
+	knobConfig, ok := knob.Application[knob.ActiveApplication]
+	if !ok {
+		return
+	}
+	event.Index = uint8(index)
+	im.handleHandlerAction(knobConfig, api.LCD, event, map[string]any{
+		"swipe_direction": direction,
+		"swipe_distance":  distance,
+	})
+}
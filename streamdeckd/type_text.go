@@ -0,0 +1,186 @@
+package streamdeckd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bendahl/uinput"
+)
+
+const typeTextKeyDelay = 5 * time.Millisecond
+
+type keyStroke struct {
+	code  int
+	shift bool
+}
+
+type keyboardLayout map[rune]keyStroke
+
+var usLayout = func() keyboardLayout {
+	layout := keyboardLayout{
+		' ':  {uinput.KeySpace, false},
+		'\n': {uinput.KeyEnter, false},
+		'\t': {uinput.KeyTab, false},
+		'-':  {uinput.KeyMinus, false},
+		'_':  {uinput.KeyMinus, true},
+		'=':  {uinput.KeyEqual, false},
+		'+':  {uinput.KeyEqual, true},
+		'[':  {uinput.KeyLeftbrace, false},
+		'{':  {uinput.KeyLeftbrace, true},
+		']':  {uinput.KeyRightbrace, false},
+		'}':  {uinput.KeyRightbrace, true},
+		';':  {uinput.KeySemicolon, false},
+		':':  {uinput.KeySemicolon, true},
+		'\'': {uinput.KeyApostrophe, false},
+		'"':  {uinput.KeyApostrophe, true},
+		'`':  {uinput.KeyGrave, false},
+		'~':  {uinput.KeyGrave, true},
+		'\\': {uinput.KeyBackslash, false},
+		'|':  {uinput.KeyBackslash, true},
+		',':  {uinput.KeyComma, false},
+		'<':  {uinput.KeyComma, true},
+		'.':  {uinput.KeyDot, false},
+		'>':  {uinput.KeyDot, true},
+		'/':  {uinput.KeySlash, false},
+		'?':  {uinput.KeySlash, true},
+	}
+	letters := []int{
+		uinput.KeyA, uinput.KeyB, uinput.KeyC, uinput.KeyD, uinput.KeyE, uinput.KeyF, uinput.KeyG,
+		uinput.KeyH, uinput.KeyI, uinput.KeyJ, uinput.KeyK, uinput.KeyL, uinput.KeyM, uinput.KeyN,
+		uinput.KeyO, uinput.KeyP, uinput.KeyQ, uinput.KeyR, uinput.KeyS, uinput.KeyT, uinput.KeyU,
+		uinput.KeyV, uinput.KeyW, uinput.KeyX, uinput.KeyY, uinput.KeyZ,
+	}
+	for i, code := range letters {
+		layout['a'+rune(i)] = keyStroke{code, false}
+		layout['A'+rune(i)] = keyStroke{code, true}
+	}
+	digits := []int{uinput.Key0, uinput.Key1, uinput.Key2, uinput.Key3, uinput.Key4, uinput.Key5, uinput.Key6, uinput.Key7, uinput.Key8, uinput.Key9}
+	for i, code := range digits {
+		layout['0'+rune(i)] = keyStroke{code, false}
+	}
+	for i, symbol := range ")!@#$%^&*(" {
+		layout[symbol] = keyStroke{digits[i], true}
+	}
+	return layout
+}()
+
+var gbLayout = func() keyboardLayout {
+	layout := keyboardLayout{}
+	for r, stroke := range usLayout {
+		layout[r] = stroke
+	}
+	layout['"'] = keyStroke{uinput.Key2, true}
+	layout['@'] = keyStroke{uinput.KeyApostrophe, true}
+	layout['£'] = keyStroke{uinput.Key3, true}
+	layout['#'] = keyStroke{uinput.KeyBackslash, false}
+	layout['~'] = keyStroke{uinput.KeyBackslash, true}
+	layout['¬'] = keyStroke{uinput.KeyGrave, true}
+	layout['\\'] = keyStroke{uinput.Key102Nd, false}
+	layout['|'] = keyStroke{uinput.Key102Nd, true}
+	return layout
+}()
+
+var keyboardLayouts = map[string]keyboardLayout{
+	"us": usLayout,
+	"gb": gbLayout,
+}
+
+// activeKeyboardLayout picks the layout from the config, falling back to the layout the session
+// says is active, then its XKB settings, then the system's, then us. Layouts it doesn't know are
+// an error, even the unicode sequences need to know where the hex digits are
+func activeKeyboardLayout() (keyboardLayout, error) {
+	name := ""
+	if configExt != nil {
+		name = configExt.KeyboardLayout
+	}
+	if name == "" {
+		var err error
+		name, err = sessionKeyboardLayout()
+		if err != nil {
+			return nil, fmt.Errorf("could not read the active keyboard layout: %w", err)
+		}
+	}
+	if name == "" {
+		name = os.Getenv("XKB_DEFAULT_LAYOUT")
+	}
+	if name == "" {
+		name = systemKeyboardLayout()
+	}
+	// only the first of several configured layouts is active by default
+	name, _, _ = strings.Cut(name, ",")
+	if name == "" {
+		name = "us"
+	}
+	layout, ok := keyboardLayouts[name]
+	if !ok {
+		return nil, fmt.Errorf("keyboard layout %s is not supported for typing text, set keyboard_layout to one that is", name)
+	}
+	return layout, nil
+}
+
+func systemKeyboardLayout() string {
+	f, err := os.Open("/etc/default/keyboard")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "XKBLAYOUT="); ok {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
+
+// TypeText types text through the virtual keyboard, characters that aren't on the keyboard
+// layout are entered as a ctrl+shift+u unicode sequence, which GTK and IBus understand
+func TypeText(text string) error {
+	if kb == nil {
+		return fmt.Errorf("virtual keyboard is unavailable")
+	}
+	layout, err := activeKeyboardLayout()
+	if err != nil {
+		return err
+	}
+	for _, r := range text {
+		if stroke, ok := layout[r]; ok {
+			err = typeKeyStroke(stroke)
+		} else {
+			err = typeUnicode(r, layout)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func typeKeyStroke(stroke keyStroke) error {
+	if stroke.shift {
+		if err := kb.KeyDown(uinput.KeyLeftshift); err != nil {
+			return err
+		}
+		defer kb.KeyUp(uinput.KeyLeftshift)
+	}
+	err := kb.KeyPress(stroke.code)
+	time.Sleep(typeTextKeyDelay)
+	return err
+}
+
+func typeUnicode(r rune, layout keyboardLayout) error {
+	err := ExecuteKeybind("ctrl+shift+u")
+	if err != nil {
+		return err
+	}
+	for _, digit := range fmt.Sprintf("%x", r) {
+		err = typeKeyStroke(layout[digit])
+		if err != nil {
+			return err
+		}
+	}
+	return typeKeyStroke(layout[' '])
+}
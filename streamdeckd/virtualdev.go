@@ -23,13 +23,18 @@ type IVirtualDev interface {
 	IsOpen() bool
 	Config() api.DeckV3
 	SetConfig(v3 api.DeckV3)
+	SetPages(pages []api.PageV3)
 	SdInfo() *api.StreamDeckInfoV1
 	Serial() string
 	Foregrounder() IForegrounder
+	PageCache() IPageCache
 	Backgrounder() IBackgrounder
 	PageManager() IPageManager
 	HandlerPruner() IHandlerPruner
 	InputManager() IInputManager
+	PageIndicator() IPageIndicator
+	DynamicPager() IDynamicPager
+	MultiStateKeys() IMultiStateKeys
 	Logger() *log.Logger
 
 	Open(rawDev *streamdeck.Device) error
@@ -37,12 +42,29 @@ type IVirtualDev interface {
 	SetKeyForeground(img image.Image, keyIndex int, page int)
 	SetPanelBackground(knobIndex int, page int)
 	SetPanelForeground(img image.Image, knobIndex int, page int)
+	SetKeyOverlay(img image.Image, keyIndex int, layer OverlayLayer)
+	SetPanelOverlay(img image.Image, knobIndex int, layer OverlayLayer)
 	RedrawKey(keyIndex int)
 	SetBrightness(brightness uint8) error
+	UpdateBrightness()
 	HandleScreenLockChange(locked bool)
 	Close()
 }
 
+// OverlayLayer is one of the images drawn over a key or panel, each is set and cleared without
+// touching the others, later layers are drawn on top
+type OverlayLayer int
+
+const (
+	IndicatorLayer OverlayLayer = iota
+	FeedbackLayer
+	// ConfirmLayer is on top, so a prompt is never hidden by a command's feedback
+	ConfirmLayer
+	overlayLayers
+)
+
+type overlayStack [overlayLayers]image.Image
+
 func OpenDevice() error {
 	connectSem.Lock()
 	defer connectSem.Unlock()
@@ -69,6 +91,7 @@ func OpenDevice() error {
 		err := dev.Open(rawDev)
 		if err == nil {
 			log.Println(fmt.Sprintf("Device (%s) connected", rawDev.Serial))
+			fireRules(ruleEvent{kind: RuleConnect, serial: rawDev.Serial})
 		}
 	}
 	return nil
@@ -85,19 +108,28 @@ type VirtualDev struct {
 	keyBGBuffs     []image.Image
 	panelFGBuffs   []image.Image
 	panelBGBuffs   []image.Image
+	keyOverlays    []overlayStack
+	panelOverlays  []overlayStack
 	roundedCorners image.Image
+	// brightness is what the deck was last set to, -1 if it hasn't been set since it connected
+	brightness   int
+	brightnessMu sync.Mutex
 
 	//External Properties
-	isOpen        bool
-	config        api.DeckV3
-	sdInfo        *api.StreamDeckInfoV1
-	deck          *streamdeck.Device
-	foregrounder  IForegrounder
-	backgrounder  IBackgrounder
-	pageManager   IPageManager
-	handlerPruner IHandlerPruner
-	inputManager  IInputManager
-	logger        *log.Logger
+	isOpen         bool
+	config         api.DeckV3
+	sdInfo         *api.StreamDeckInfoV1
+	deck           *streamdeck.Device
+	foregrounder   IForegrounder
+	pageCache      IPageCache
+	backgrounder   IBackgrounder
+	pageManager    IPageManager
+	handlerPruner  IHandlerPruner
+	inputManager   IInputManager
+	pageIndicator  IPageIndicator
+	dynamicPager   IDynamicPager
+	multiStateKeys IMultiStateKeys
+	logger         *log.Logger
 }
 
 func (dev *VirtualDev) Open(rawDev *streamdeck.Device) error {
@@ -112,6 +144,7 @@ func (dev *VirtualDev) Open(rawDev *streamdeck.Device) error {
 		// initial connect
 		config := findConfig(rawDev)
 		dev = &VirtualDev{
+			brightness:     -1,
 			deck:           rawDev,
 			isOpen:         true,
 			config:         config,
@@ -121,6 +154,8 @@ func (dev *VirtualDev) Open(rawDev *streamdeck.Device) error {
 			keyFGBuffs:     make([]image.Image, rawDev.Keys),
 			panelBGBuffs:   make([]image.Image, rawDev.LcdColumns),
 			panelFGBuffs:   make([]image.Image, rawDev.LcdColumns),
+			keyOverlays:    make([]overlayStack, rawDev.Keys),
+			panelOverlays:  make([]overlayStack, rawDev.LcdColumns),
 		}
 		dev.setSdInfo()
 
@@ -134,6 +169,10 @@ func (dev *VirtualDev) Open(rawDev *streamdeck.Device) error {
 			vdev: dev,
 		}
 
+		dev.pageCache = &PageCache{
+			vdev: dev,
+		}
+
 		dev.pageManager = &PageManager{
 			vdev: dev,
 			page: 0,
@@ -152,21 +191,77 @@ func (dev *VirtualDev) Open(rawDev *streamdeck.Device) error {
 			vdev: dev,
 		}
 
+		dev.pageIndicator = &PageIndicator{
+			vdev:      dev,
+			activeKey: -1,
+		}
+
+		dev.dynamicPager = &DynamicPager{
+			vdev: dev,
+			slot: -1,
+		}
+
+		dev.multiStateKeys = &MultiStateKeys{
+			vdev: dev,
+		}
+
 		dev.backgrounder.AttachPageChangeListener()
 
-		dev.pageManager.AttachListener(func(_, _ int) {
-			dev.keyFGBuffs = make([]image.Image, rawDev.Keys)
-			dev.panelFGBuffs = make([]image.Image, rawDev.LcdColumns)
+		dev.pageManager.AttachListener(func(newPage, _ int) {
+			keyFGBuffs := make([]image.Image, rawDev.Keys)
+			panelFGBuffs := make([]image.Image, rawDev.LcdColumns)
+			page := dev.config.Pages[newPage]
+			for i := range page.Keys {
+				if i < len(keyFGBuffs) {
+					keyFGBuffs[i] = dev.pageCache.Key(i, newPage, keyApplication(&page.Keys[i]))
+				}
+			}
+			for i := range page.Knobs {
+				if i < len(panelFGBuffs) {
+					panelFGBuffs[i] = dev.pageCache.Panel(i, newPage, knobApplication(&page.Knobs[i]))
+				}
+			}
+			dev.keyFGBuffs = keyFGBuffs
+			dev.panelFGBuffs = panelFGBuffs
+			for i := range keyFGBuffs {
+				if keyFGBuffs[i] != nil {
+					dev.keyUpdateChan <- i
+				}
+			}
+			for i := range panelFGBuffs {
+				if panelFGBuffs[i] != nil {
+					dev.knobUpdateChan <- i
+				}
+			}
 		})
 
 		dev.foregrounder.AttachPageChangeListener()
 		dev.foregrounder.AttachAppChangeListener()
 
+		dev.pageCache.AttachPageChangeListener()
+
+		dev.pageIndicator.AttachPageChangeListener()
+		dev.dynamicPager.AttachPageChangeListener()
+		dev.multiStateKeys.AttachPageChangeListener()
+		dev.inputManager.AttachPageChangeListener()
+
+		dev.pageManager.AttachListener(func(newPage, previousPage int) {
+			if newPage != previousPage {
+				fireRules(ruleEvent{kind: RulePage, serial: rawDev.Serial, page: newPage})
+			}
+		})
+
 		dev.handlerPruner.OnPageChange()
 		dev.handlerPruner.OnAppSwitch()
 
+		applicationManager.AttachListener(func(_ ApplicationContext) {
+			dev.UpdateBrightness()
+		})
+
 		dev.logger = log.New(os.Stdout, fmt.Sprintf("(%s) ", dev.sdInfo.Serial), log.Lshortfile|log.Ltime)
 
+		dev.inputManager.LoadInputLock()
+
 		Devs[rawDev.Serial] = dev
 	} else {
 		//reconnect
@@ -174,6 +269,11 @@ func (dev *VirtualDev) Open(rawDev *streamdeck.Device) error {
 		dev.deck = rawDev
 		dev.sdInfo.LastConnected = time.Now()
 		dev.sdInfo.Connected = true
+		// the deck forgets its brightness when it is unplugged
+		dev.brightnessMu.Lock()
+		dev.brightness = -1
+		dev.brightnessMu.Unlock()
+		dev.UpdateBrightness()
 	}
 
 	dev.pageManager.SetPage(dev.pageManager.GetPage())
@@ -197,12 +297,20 @@ func (dev *VirtualDev) Config() api.DeckV3 {
 }
 
 func (dev *VirtualDev) SetConfig(config api.DeckV3) {
+	dev.dynamicPager.Close()
+	dev.pageCache.Clear()
+
 	dev.config = config
+	dev.inputManager.LoadInputLock()
 
 	go dev.backgrounder.SetKeyBackground(&dev.config)
 	go dev.backgrounder.SetLcdBackground(&dev.config)
 
-	dev.pageManager.Refresh()
+	if dev.pageManager.GetPage() >= len(dev.config.Pages) {
+		dev.pageManager.SetPage(0)
+	} else {
+		dev.pageManager.Refresh()
+	}
 
 	page := dev.pageManager.GetPage()
 	for i := range dev.keyBGBuffs {
@@ -213,6 +321,10 @@ func (dev *VirtualDev) SetConfig(config api.DeckV3) {
 	}
 }
 
+func (dev *VirtualDev) SetPages(pages []api.PageV3) {
+	dev.config.Pages = pages
+}
+
 func (dev *VirtualDev) SdInfo() *api.StreamDeckInfoV1 {
 	return dev.sdInfo
 }
@@ -225,6 +337,10 @@ func (dev *VirtualDev) Foregrounder() IForegrounder {
 	return dev.foregrounder
 }
 
+func (dev *VirtualDev) PageCache() IPageCache {
+	return dev.pageCache
+}
+
 func (dev *VirtualDev) Backgrounder() IBackgrounder {
 	return dev.backgrounder
 }
@@ -241,6 +357,18 @@ func (dev *VirtualDev) InputManager() IInputManager {
 	return dev.inputManager
 }
 
+func (dev *VirtualDev) PageIndicator() IPageIndicator {
+	return dev.pageIndicator
+}
+
+func (dev *VirtualDev) DynamicPager() IDynamicPager {
+	return dev.dynamicPager
+}
+
+func (dev *VirtualDev) MultiStateKeys() IMultiStateKeys {
+	return dev.multiStateKeys
+}
+
 func (dev *VirtualDev) Logger() *log.Logger {
 	return dev.logger
 }
@@ -274,7 +402,7 @@ func (dev *VirtualDev) SetKeyBackground(keyIndex int, page int) {
 	}
 
 	if background == nil {
-		pbg := dev.config.Pages[page].GetTouchPanelBackgroundBuff()
+		pbg := dev.config.Pages[page].GetKeyGridBackgroundBuff()
 
 		if pbg != nil {
 			background = pbg[keyIndex]
@@ -300,7 +428,8 @@ func (dev *VirtualDev) RedrawKey(keyIndex int) {
 }
 
 func (dev *VirtualDev) SetKeyForeground(img image.Image, keyIndex int, page int) {
-	if dev.pageManager.GetPage() != page {
+	onPage := dev.pageManager.GetPage() == page
+	if !onPage && !dev.pageCache.Wants(page) {
 		return
 	}
 
@@ -309,6 +438,14 @@ func (dev *VirtualDev) SetKeyForeground(img image.Image, keyIndex int, page int)
 		img = api.ResizeImage(img, dev.sdInfo.IconSize)
 	}
 
+	if page < len(dev.config.Pages) && keyIndex < len(dev.config.Pages[page].Keys) {
+		dev.pageCache.StoreKey(img, keyIndex, page, dev.config.Pages[page].Keys[keyIndex].ActiveApplication)
+	}
+
+	if !onPage {
+		return
+	}
+
 	if dev.keyFGBuffs[keyIndex] != img {
 		dev.keyFGBuffs[keyIndex] = img
 		dev.keyUpdateChan <- keyIndex
@@ -364,7 +501,8 @@ func (dev *VirtualDev) SetPanelBackground(knobIndex int, page int) {
 }
 
 func (dev *VirtualDev) SetPanelForeground(img image.Image, knobIndex int, page int) {
-	if dev.pageManager.GetPage() != page {
+	onPage := dev.pageManager.GetPage() == page
+	if !onPage && !dev.pageCache.Wants(page) {
 		return
 	}
 
@@ -373,14 +511,90 @@ func (dev *VirtualDev) SetPanelForeground(img image.Image, knobIndex int, page i
 		img = api.ResizeImageWH(img, dev.sdInfo.LcdWidth, dev.sdInfo.LcdHeight)
 	}
 
+	if page < len(dev.config.Pages) && knobIndex < len(dev.config.Pages[page].Knobs) {
+		dev.pageCache.StorePanel(img, knobIndex, page, dev.config.Pages[page].Knobs[knobIndex].ActiveApplication)
+	}
+
+	if !onPage {
+		return
+	}
+
 	if dev.panelFGBuffs[knobIndex] != img {
 		dev.panelFGBuffs[knobIndex] = img
 		dev.knobUpdateChan <- knobIndex
 	}
 }
 
+func (dev *VirtualDev) SetKeyOverlay(img image.Image, keyIndex int, layer OverlayLayer) {
+	if keyIndex >= len(dev.keyOverlays) || dev.keyOverlays[keyIndex][layer] == img {
+		return
+	}
+	dev.keyOverlays[keyIndex][layer] = img
+	dev.keyUpdateChan <- keyIndex
+}
+
+func (dev *VirtualDev) SetPanelOverlay(img image.Image, knobIndex int, layer OverlayLayer) {
+	if knobIndex >= len(dev.panelOverlays) || dev.panelOverlays[knobIndex][layer] == img {
+		return
+	}
+	dev.panelOverlays[knobIndex][layer] = img
+	dev.knobUpdateChan <- knobIndex
+}
+
+// SetBrightness sets the brightness chosen by the user, which is remembered for the deck and
+// used whenever the current page or application doesn't set its own
 func (dev *VirtualDev) SetBrightness(brightness uint8) error {
-	return dev.deck.SetBrightness(brightness)
+	updateDeckState(dev.Serial(), func(deckState *DeckStateV1) {
+		saved := int(brightness)
+		deckState.Brightness = &saved
+	})
+	err := SaveState()
+	if err != nil {
+		dev.logger.Println(err)
+	}
+	dev.brightnessMu.Lock()
+	defer dev.brightnessMu.Unlock()
+	return dev.applyBrightness(int(brightness))
+}
+
+func (dev *VirtualDev) UpdateBrightness() {
+	brightness, ok := savedBrightness(dev.Serial())
+	deckExt := findDeckExt(dev.Serial())
+	if pageExt := deckExt.Page(dev.pageManager.GetPage()); pageExt != nil && pageExt.Brightness != 0 {
+		brightness, ok = pageExt.Brightness, true
+	}
+	if deckExt != nil {
+		application := matchApplication(deckExt.ApplicationBrightness, applicationManager.GetContext())
+		if appBrightness, found := deckExt.ApplicationBrightness[application]; found {
+			brightness, ok = appBrightness, true
+		}
+	}
+	dev.brightnessMu.Lock()
+	defer dev.brightnessMu.Unlock()
+	if !ok {
+		// nothing has set a brightness, leave the deck at its own unless something had
+		if dev.brightness < 0 {
+			return
+		}
+		brightness = 100
+	}
+	err := dev.applyBrightness(brightness)
+	if err != nil {
+		dev.logger.Println(err)
+	}
+}
+
+// applyBrightness must be called with brightnessMu held
+func (dev *VirtualDev) applyBrightness(brightness int) error {
+	if !dev.isOpen || dev.brightness == brightness {
+		return nil
+	}
+	err := dev.deck.SetBrightness(uint8(brightness))
+	if err != nil {
+		return err
+	}
+	dev.brightness = brightness
+	return nil
 }
 
 func (dev *VirtualDev) setSdInfo() {
@@ -444,7 +658,8 @@ func (dev *VirtualDev) renderKey() {
 
 		keyIndex := <-dev.keyUpdateChan
 
-		mergedImage, err := api.LayerImages(dev.sdInfo.IconSize, dev.sdInfo.IconSize, dev.keyBGBuffs[keyIndex], dev.keyFGBuffs[keyIndex], dev.roundedCorners)
+		layers := append([]image.Image{dev.keyBGBuffs[keyIndex], dev.keyFGBuffs[keyIndex]}, dev.keyOverlays[keyIndex][:]...)
+		mergedImage, err := api.LayerImages(dev.sdInfo.IconSize, dev.sdInfo.IconSize, append(layers, dev.roundedCorners)...)
 
 		if err != nil {
 			if err.Error() == "no images supplied" || err.Error() == "no valid images supplied" {
@@ -497,7 +712,8 @@ func (dev *VirtualDev) renderKnob() {
 
 		knobIndex := <-dev.knobUpdateChan
 
-		mergedImage, err := api.LayerImages(dev.sdInfo.LcdWidth, dev.sdInfo.LcdHeight, dev.panelBGBuffs[knobIndex], dev.panelFGBuffs[knobIndex])
+		layers := append([]image.Image{dev.panelBGBuffs[knobIndex], dev.panelFGBuffs[knobIndex]}, dev.panelOverlays[knobIndex][:]...)
+		mergedImage, err := api.LayerImages(dev.sdInfo.LcdWidth, dev.sdInfo.LcdHeight, layers...)
 
 		if err != nil {
 			if err.Error() == "no images supplied" || err.Error() == "no valid images supplied" {
@@ -543,21 +759,17 @@ func (dev *VirtualDev) handleInput() {
 	}()
 	dev.deck.HandleInput(func(event streamdeck.InputEvent) {
 		if !locked {
+			if dev.inputManager.InputLocked() {
+				dev.inputManager.HandleLockedInput(event)
+				return
+			}
 			if event.EventType == streamdeck.KEY_PRESS || event.EventType == streamdeck.KEY_RELEASE {
 				page := dev.config.Pages[dev.pageManager.GetPage()]
 				if uint8(len(page.Keys)) > event.Index {
 					dev.inputManager.HandleKeyInput(&page.Keys[event.Index], event)
 				}
 			} else if event.EventType == streamdeck.SCREEN_SWIPE {
-				if event.ScreenEndX < event.ScreenX {
-					if dev.pageManager.GetPage() < len(dev.config.Pages)-1 {
-						dev.pageManager.SetPage(dev.pageManager.GetPage() + 1)
-					}
-				} else {
-					if dev.pageManager.GetPage() > 0 {
-						dev.pageManager.SetPage(dev.pageManager.GetPage() - 1)
-					}
-				}
+				dev.inputManager.HandleSwipe(event)
 			} else if dev.deck.HasLCD && dev.deck.HasKnobs {
 				page := dev.config.Pages[dev.pageManager.GetPage()]
 				if uint8(len(page.Knobs)) > event.Index {
@@ -583,6 +795,9 @@ func (dev *VirtualDev) disconnect() {
 	dev.sdInfo.Connected = false
 	dev.sdInfo.LastDisconnected = time.Now()
 	dev.handlerPruner.StopAllHandlers()
+	if !dev.shuttingDown {
+		fireRules(ruleEvent{kind: RuleDisconnect, serial: dev.deck.Serial})
+	}
 }
 
 func (dev *VirtualDev) Close() {
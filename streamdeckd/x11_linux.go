@@ -0,0 +1,179 @@
+//go:build linux
+
+package streamdeckd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	x "github.com/linuxdeepin/go-x11-client"
+)
+
+type X11ActiveWindow struct {
+	Window x.Window
+	Class  string
+	Title  string
+	Pid    int
+}
+
+var c *x.Conn
+
+var x11WindowSem sync.Mutex
+var x11Window X11ActiveWindow
+
+// GetX11ActiveWindow returns the last active window reported by the X11 window manager
+func GetX11ActiveWindow() X11ActiveWindow {
+	x11WindowSem.Lock()
+	defer x11WindowSem.Unlock()
+	return x11Window
+}
+
+type x11Atoms struct {
+	activeWindow x.Atom
+	wmName       x.Atom
+	wmPid        x.Atom
+	utf8String   x.Atom
+}
+
+type X11Provider struct {
+	stopped atomic.Bool
+}
+
+func (p *X11Provider) Available() bool {
+	return os.Getenv("XDG_SESSION_TYPE") == "x11"
+}
+
+// Start reads the active window, then follows PropertyNotify events on the root window for
+// _NET_ACTIVE_WINDOW, and on the active window for its title, until the connection drops
+func (p *X11Provider) Start(contexts chan<- ApplicationContext) error {
+	p.stopped.Store(false)
+	var err error
+	c, err = x.NewConn()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	atoms, err := getX11Atoms()
+	if err != nil {
+		return err
+	}
+
+	root := c.GetDefaultScreen().Root
+	events := c.MakeAndAddEventChan(50)
+	err = x.ChangeWindowAttributesChecked(c, root, x.CWEventMask, []uint32{x.EventMaskPropertyChange}).Check(c)
+	if err != nil {
+		return err
+	}
+
+	// a new connection hasn't asked for events from any window yet
+	x11WindowSem.Lock()
+	x11Window = X11ActiveWindow{}
+	x11WindowSem.Unlock()
+
+	updateX11Window(contexts, atoms, root)
+	for ev := range events {
+		if ev.GetEventCode() != x.PropertyNotifyEventCode {
+			continue
+		}
+		event, err := x.NewPropertyNotifyEvent(ev)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		switch {
+		case event.Window == root && event.Atom == atoms.activeWindow:
+			updateX11Window(contexts, atoms, root)
+		case event.Window == GetX11ActiveWindow().Window && event.Atom == atoms.wmName:
+			title := getX11String(event.Window, atoms.wmName, atoms.utf8String)
+			x11WindowSem.Lock()
+			x11Window.Title = title
+			window := x11Window
+			x11WindowSem.Unlock()
+			contexts <- ApplicationContext{Class: window.Class, Title: window.Title, Pid: window.Pid}
+		}
+	}
+	if p.stopped.Load() {
+		return nil
+	}
+	return errors.New("X11 connection closed")
+}
+
+func (p *X11Provider) Stop() {
+	p.stopped.Store(true)
+	if c != nil {
+		c.Close()
+	}
+}
+
+func getX11Atoms() (*x11Atoms, error) {
+	var atoms x11Atoms
+	var err error
+	for name, atom := range map[string]*x.Atom{
+		"_NET_ACTIVE_WINDOW": &atoms.activeWindow,
+		"_NET_WM_NAME":       &atoms.wmName,
+		"_NET_WM_PID":        &atoms.wmPid,
+		"UTF8_STRING":        &atoms.utf8String,
+	} {
+		*atom, err = c.GetAtom(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &atoms, nil
+}
+
+func updateX11Window(contexts chan<- ApplicationContext, atoms *x11Atoms, root x.Window) {
+	window := X11ActiveWindow{}
+	reply, err := x.GetProperty(c, false, root, atoms.activeWindow, x.AtomWindow, 0, 1).Reply(c)
+	if err == nil && len(reply.Value) >= 4 {
+		window.Window = x.Window(binary.NativeEndian.Uint32(reply.Value))
+	}
+	previous := GetX11ActiveWindow().Window
+	if previous != 0 && previous != window.Window && previous != root {
+		// event masks are kept per client, so this only stops our own events from the window
+		// that lost focus, it may already be gone in which case the error is ignored
+		x.ChangeWindowAttributes(c, previous, x.CWEventMask, []uint32{x.EventMaskNoEvent})
+	}
+	if window.Window != 0 && window.Window != previous {
+		// follow the window's own properties too, so title changes are picked up
+		x.ChangeWindowAttributes(c, window.Window, x.CWEventMask, []uint32{x.EventMaskPropertyChange})
+	}
+	if window.Window != 0 {
+		window.Class = getX11Class(window.Window)
+		window.Title = getX11String(window.Window, atoms.wmName, atoms.utf8String)
+		pid, err := x.GetProperty(c, false, window.Window, atoms.wmPid, x.AtomCardinal, 0, 1).Reply(c)
+		if err == nil && len(pid.Value) >= 4 {
+			window.Pid = int(binary.NativeEndian.Uint32(pid.Value))
+		}
+	}
+
+	x11WindowSem.Lock()
+	x11Window = window
+	x11WindowSem.Unlock()
+	contexts <- ApplicationContext{Class: window.Class, Title: window.Title, Pid: window.Pid}
+}
+
+// getX11Class returns the class half of WM_CLASS, which holds the instance and class names
+// as two null terminated strings
+func getX11Class(window x.Window) string {
+	reply, err := x.GetProperty(c, false, window, x.AtomWMClass, x.AtomString, 0, 1024).Reply(c)
+	if err != nil {
+		return ""
+	}
+	parts := bytes.Split(bytes.TrimRight(reply.Value, "\x00"), []byte{0})
+	return string(parts[len(parts)-1])
+}
+
+func getX11String(window x.Window, property x.Atom, propertyType x.Atom) string {
+	reply, err := x.GetProperty(c, false, window, property, propertyType, 0, 1024).Reply(c)
+	if err != nil {
+		return ""
+	}
+	return string(reply.Value)
+}